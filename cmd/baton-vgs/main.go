@@ -24,9 +24,31 @@ const (
 var (
 	ServiceAccountClientId     = field.StringField(client.ServiceAccountClientIdName, field.WithRequired(true), field.WithDescription("The VGS client id."))
 	ServiceAccountClientSecret = field.StringField(client.ServiceAccountClientSecretName, field.WithRequired(true), field.WithDescription("The VGS client secret."))
-	OrganizationId             = field.StringField(client.OrganizationId, field.WithRequired(true), field.WithDescription("The VGS organization id."))
+	OrganizationId             = field.StringSliceField(client.OrganizationId, field.WithDescription("The VGS organization ids to sync. Defaults to every organization the service account can see."))
 	Vault                      = field.StringField(client.VaultId, field.WithRequired(true), field.WithDescription("The VGS vault id."))
-	configurationFields        = []field.SchemaField{Vault, ServiceAccountClientId, ServiceAccountClientSecret, OrganizationId}
+	AuthRealmURL               = field.StringField(client.AuthRealmURL, field.WithDefaultValue(client.DefaultAuthRealmURL), field.WithDescription("The VGS auth realm URL used to issue access tokens."))
+	AccountsAPIURL             = field.StringField(client.AccountsAPIURL, field.WithDefaultValue(client.DefaultAccountsAPIURL), field.WithDescription("The VGS Accounts API base URL."))
+	AllowInsecureEndpoints     = field.BoolField(client.AllowInsecureEndpoints, field.WithDescription("Allow non-HTTPS auth and API URLs. For testing only."))
+	InviteStatuses             = field.StringSliceField(client.InviteStatuses,
+		field.WithDefaultValue([]string{"PENDING"}),
+		field.WithDescription("The invite statuses to sync, e.g. PENDING, ACCEPTED or EXPIRED."),
+	)
+	RevokePreviousSecrets          = field.BoolField(client.RevokePreviousSecrets, field.WithDescription("Revoke the previous secret when rotating a service account secret."))
+	AllowSelfRotation              = field.BoolField(client.AllowSelfRotation, field.WithDescription("Allow rotating the secret of the service account the connector runs with."))
+	RevokeReplacedVaultCredentials = field.BoolField(client.RevokeReplacedVaultCredentials, field.WithDescription("Revoke the replaced credential when rotating a vault credential."))
+	configurationFields            = []field.SchemaField{
+		Vault,
+		ServiceAccountClientId,
+		ServiceAccountClientSecret,
+		OrganizationId,
+		AuthRealmURL,
+		AccountsAPIURL,
+		AllowInsecureEndpoints,
+		InviteStatuses,
+		RevokePreviousSecrets,
+		AllowSelfRotation,
+		RevokeReplacedVaultCredentials,
+	}
 )
 
 func main() {
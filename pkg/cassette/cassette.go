@@ -0,0 +1,91 @@
+// Package cassette records HTTP exchanges with the Accounts API into reviewable JSON files and replays them
+// offline. Secrets and email addresses are redacted before anything is written to disk.
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Cassette is a recorded sequence of HTTP exchanges.
+type Cassette struct {
+	// Values holds the identifiers a test needs to issue the same requests again on replay.
+	Values       map[string]string `json:"values,omitempty"`
+	Interactions []Interaction     `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. JSON bodies are kept in Body, anything else, such as form bodies, in Text.
+type Request struct {
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Text    string          `json:"text,omitempty"`
+}
+
+// Response is a recorded response, with bodies stored like in Request.
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	Headers    http.Header     `json:"headers,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Text       string          `json:"text,omitempty"`
+}
+
+// Load reads a cassette from path.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette: invalid cassette %s: %w", path, err)
+	}
+
+	return &c, nil
+}
+
+// Save writes the cassette to path as indented JSON, creating parent directories as needed.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// encodeBody stores JSON bodies as-is so they stay readable in the cassette and everything else as text.
+func encodeBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+
+	if json.Valid(body) {
+		return json.RawMessage(body), ""
+	}
+
+	return nil, string(body)
+}
+
+// decodeBody reverses encodeBody.
+func decodeBody(body json.RawMessage, text string) []byte {
+	if len(body) > 0 {
+		return body
+	}
+
+	return []byte(text)
+}
@@ -0,0 +1,89 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor()
+
+	assert.Equal(t, "user1@example.invalid invited user2@example.invalid", r.String("Alice@Example.com invited bob@example.com"))
+	assert.Equal(t, "user1@example.invalid", r.String("alice@example.com"))
+
+	body := r.Body([]byte(`{"access_token":"eyJ.secret","expires_in":300,"data":[{"email":"bob@example.com"}]}`), "application/json")
+	assert.JSONEq(t, `{"access_token":"REDACTED","expires_in":300,"data":[{"email":"user2@example.invalid"}]}`, string(body))
+
+	form := r.Body([]byte("grant_type=client_credentials&client_secret=hunter2"), "application/x-www-form-urlencoded")
+	assert.Equal(t, "client_secret=REDACTED&grant_type=client_credentials", string(form))
+
+	h := r.Headers(http.Header{
+		"Authorization": {"Bearer eyJ.secret"},
+		"Cookie":        {"session=1"},
+		"Content-Type":  {"application/vnd.api+json"},
+	})
+	assert.Equal(t, "Bearer REDACTED", h.Get("Authorization"))
+	assert.Empty(t, h.Get("Cookie"))
+	assert.Equal(t, "application/vnd.api+json", h.Get("Content-Type"))
+
+	assert.Equal(t, "https://api.example.com/users?filter%5Bemail%5D=user2%40example.invalid", r.URL("https://api.example.com/users?filter[email]=bob@example.com"))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.Header().Set("Set-Cookie", "session=1")
+		_, _ = io.WriteString(w, `{"data":{"id":"u1","attributes":{"email":"carol@example.com","path":"`+r.URL.Path+`"}}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	rec := NewRecorder(nil)
+	cli := &http.Client{Transport: rec}
+	for _, path := range []string{"/a?page[number]=1", "/b", "/a?page[number]=2"} {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+path, strings.NewReader(`{"role":"admin"}`))
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		resp, err := cli.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+	}
+	rec.SetValue("user", "carol@example.com")
+
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+	assert.Nil(t, rec.Save(path))
+
+	c, err := Load(path)
+	assert.Nil(t, err)
+	assert.Len(t, c.Interactions, 3)
+	assert.Equal(t, "user1@example.invalid", c.Values["user"])
+	assert.Equal(t, "Bearer REDACTED", c.Interactions[0].Request.Headers.Get("Authorization"))
+	assert.Empty(t, c.Interactions[0].Response.Headers.Get("Set-Cookie"))
+
+	replay := NewReplayer(c)
+	cli = &http.Client{Transport: replay}
+	put := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPut, "https://elsewhere.invalid/a?page%5Bnumber%5D=2", strings.NewReader(`{"role":"admin"}`))
+		assert.Nil(t, err)
+		return cli.Do(req)
+	}
+
+	resp, err := put()
+	if !assert.Nil(t, err) {
+		return
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "user1@example.invalid")
+	assert.NotContains(t, string(body), "carol")
+
+	_, err = put()
+	assert.NotNil(t, err)
+	assert.Len(t, replay.Unused(), 2)
+}
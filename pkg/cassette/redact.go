@@ -0,0 +1,174 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in recorded cassettes.
+const Redacted = "REDACTED"
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// secretFields are JSON and form fields whose values are never written to a cassette.
+var secretFields = map[string]struct{}{
+	"access_token":  {},
+	"refresh_token": {},
+	"id_token":      {},
+	"client_secret": {},
+	"secret":        {},
+	"password":      {},
+	"session_state": {},
+}
+
+// keptHeaders are the only headers recorded. Everything else, including cookies, is dropped.
+var keptHeaders = []string{
+	"Accept",
+	"Authorization",
+	"Content-Type",
+	"Retry-After",
+	"Ratelimit-Limit",
+	"Ratelimit-Remaining",
+	"Ratelimit-Reset",
+	"X-Ratelimit-Limit",
+	"X-Ratelimit-Remaining",
+	"X-Ratelimit-Reset",
+}
+
+// Redactor scrubs secrets and email addresses. Each distinct email address is replaced by the same pseudonym
+// everywhere in a cassette, so recorded requests and responses still refer to each other consistently.
+type Redactor struct {
+	mtx    sync.Mutex
+	emails map[string]string
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{emails: map[string]string{}}
+}
+
+// String replaces every email address in s with its pseudonym.
+func (r *Redactor) String(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+
+		key := strings.ToLower(email)
+		pseudonym, ok := r.emails[key]
+		if !ok {
+			pseudonym = fmt.Sprintf("user%d@example.invalid", len(r.emails)+1)
+			r.emails[key] = pseudonym
+		}
+
+		return pseudonym
+	})
+}
+
+// URL redacts email addresses in the path and query of raw.
+func (r *Redactor) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return r.String(raw)
+	}
+
+	query := u.Query()
+	for key, values := range query {
+		for i, v := range values {
+			values[i] = r.String(v)
+		}
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	u.Path = r.String(u.Path)
+	u.RawPath = ""
+
+	return u.String()
+}
+
+// Headers keeps the allowed headers only and hides credentials. The auth scheme is kept so it can still be
+// reviewed which kind of credential a request carried.
+func (r *Redactor) Headers(h http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range keptHeaders {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		for _, v := range values {
+			if name == "Authorization" {
+				scheme, _, _ := strings.Cut(v, " ")
+				v = scheme + " " + Redacted
+			}
+			kept.Add(name, r.String(v))
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+
+	return kept
+}
+
+// Body redacts a request or response body. JSON bodies have secret fields blanked and email addresses replaced,
+// form bodies have secret fields blanked, and anything else only has email addresses replaced.
+func (r *Redactor) Body(body []byte, contentType string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	var doc any
+	if json.Unmarshal(body, &doc) == nil {
+		redacted, err := json.Marshal(r.value(doc))
+		if err == nil {
+			return redacted
+		}
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for key, values := range form {
+				for i, v := range values {
+					if _, secret := secretFields[key]; secret {
+						values[i] = Redacted
+						continue
+					}
+					values[i] = r.String(v)
+				}
+			}
+			return []byte(form.Encode())
+		}
+	}
+
+	return []byte(r.String(string(body)))
+}
+
+func (r *Redactor) value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if _, secret := secretFields[key]; secret {
+				if _, ok := inner.(string); ok {
+					v[key] = Redacted
+					continue
+				}
+			}
+			v[key] = r.value(inner)
+		}
+		return v
+	case []any:
+		for i, inner := range v {
+			v[i] = r.value(inner)
+		}
+		return v
+	case string:
+		return r.String(v)
+	default:
+		return v
+	}
+}
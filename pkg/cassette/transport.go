@@ -0,0 +1,190 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Recorder is an http.RoundTripper that forwards requests to the wrapped transport and records the redacted
+// exchanges into a cassette.
+type Recorder struct {
+	next     http.RoundTripper
+	redactor *Redactor
+
+	mtx      sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder records the exchanges going through next, which defaults to http.DefaultTransport.
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{next: next, redactor: NewRedactor()}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     r.redactor.URL(req.URL.String()),
+			Headers: r.redactor.Headers(req.Header),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    r.redactor.Headers(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.Text = encodeBody(r.redactor.Body(reqBody, req.Header.Get("Content-Type")))
+	interaction.Response.Body, interaction.Response.Text = encodeBody(r.redactor.Body(respBody, resp.Header.Get("Content-Type")))
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+
+	return resp, nil
+}
+
+// SetValue stores an identifier the test needs on replay. Email addresses in it are redacted like everywhere else.
+func (r *Recorder) SetValue(key, value string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.cassette.Values == nil {
+		r.cassette.Values = map[string]string{}
+	}
+	r.cassette.Values[key] = r.redactor.String(value)
+}
+
+// Save writes everything recorded so far to path.
+func (r *Recorder) Save(path string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.cassette.Save(path)
+}
+
+// Replayer is an http.RoundTripper answering requests from a cassette without touching the network.
+// Requests are matched on method, path and query, ignoring the host, and each interaction is used once in order.
+type Replayer struct {
+	mtx      sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{cassette: c, used: make([]bool, len(c.Interactions))}
+}
+
+// Value returns an identifier stored while recording.
+func (r *Replayer) Value(key string) string {
+	return r.cassette.Values[key]
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	key := requestKey(req.Method, req.URL)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+
+		recorded, err := url.Parse(interaction.Request.URL)
+		if err != nil || requestKey(interaction.Request.Method, recorded) != key {
+			continue
+		}
+
+		r.used[i] = true
+		header := interaction.Response.Headers.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		body := decodeBody(interaction.Response.Body, interaction.Response.Text)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette: no recorded interaction left for %s", key)
+}
+
+// Unused returns the recorded requests that were never replayed.
+func (r *Replayer) Unused() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var unused []string
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction.Request.Method+" "+interaction.Request.URL)
+		}
+	}
+
+	return unused
+}
+
+// requestKey identifies a request by method, path and sorted query, ignoring scheme and host.
+func requestKey(method string, u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	return method + " " + u.EscapedPath() + "?" + strings.Join(parts, "&")
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conductorone/baton-vgs/pkg/cassette"
+	"github.com/conductorone/baton-vgs/pkg/vgsfake"
+	"github.com/stretchr/testify/assert"
+)
+
+// Cassette tests replay HTTP exchanges recorded against VGS from testdata/cassettes, and are skipped while no
+// cassette has been recorded. Set VGS_CASSETTE_MODE to record instead:
+//
+//	VGS_CASSETTE_MODE=live  records into testdata/cassettes against VGS using the BATON_* credentials.
+//	                        VGS_CASSETTE_USER_ID names a vault member whose role is written back unchanged,
+//	                        VGS_CASSETTE_REVOKE_USER_ID optionally names one whose vault access is revoked.
+//	VGS_CASSETTE_MODE=fake  runs the scenario against pkg/vgsfake seeded with its basic fixture and records into a
+//	                        temporary directory. A recording of the fake says nothing about VGS, so it is never
+//	                        written to testdata.
+const cassetteModeEnv = "VGS_CASSETTE_MODE"
+
+const (
+	cassetteOrganizationId = "organization_id"
+	cassetteVaultId        = "vault_id"
+	cassetteUserId         = "user_id"
+	cassetteRevokeUserId   = "revoke_user_id"
+)
+
+// newCassetteClientForTesting returns a client whose traffic is replayed from, or recorded into, the named
+// cassette, along with the identifiers the scenario runs against.
+func newCassetteClientForTesting(t *testing.T, name string) (*VGSClient, map[string]string) {
+	path := filepath.Join("testdata", "cassettes", name+".json")
+	cfg := Config{}
+
+	var (
+		values   map[string]string
+		recorder *cassette.Recorder
+	)
+	switch mode := os.Getenv(cassetteModeEnv); mode {
+	case "":
+		c, err := cassette.Load(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Skipf("no cassette recorded against VGS at %s; record one with %s=live", path, cassetteModeEnv)
+		}
+		if err != nil {
+			t.Fatalf("loading cassette: %v", err)
+		}
+
+		replayer := cassette.NewReplayer(c)
+		t.Cleanup(func() {
+			assert.Empty(t, replayer.Unused(), "recorded requests were not replayed")
+		})
+		cfg.WithServiceAccountClientId("replay").
+			WithServiceAccountClientSecret("replay").
+			WithTransportWrapper(func(http.RoundTripper) http.RoundTripper { return replayer })
+
+		values = c.Values
+	case "fake":
+		path = filepath.Join(t.TempDir(), name+".json")
+		s := vgsfake.NewFromFile(t, fixtureForTesting)
+		cfg.WithServiceAccountClientId("ACbbb-admin").
+			WithServiceAccountClientSecret("admin-secret").
+			WithAuthRealmURL(s.AuthRealmURL()).
+			WithAccountsAPIURL(s.AccountsAPIURL()).
+			WithAllowInsecureEndpoints(true)
+
+		values = map[string]string{
+			cassetteOrganizationId: "ACorg1",
+			cassetteVaultId:        "tntsandbox",
+			cassetteUserId:         "IDbob",
+			cassetteRevokeUserId:   "IDbob",
+		}
+	case "live":
+		if clientId == "" || clientSecret == "" || orgId == "" || vaultId == "" {
+			t.Skip("live recording needs BATON_SERVICE_ACCOUNT_CLIENT_ID, BATON_SERVICE_ACCOUNT_CLIENT_SECRET, BATON_ORGANIZATION_ID and BATON_VAULT")
+		}
+		cfg.WithServiceAccountClientId(clientId).WithServiceAccountClientSecret(clientSecret)
+
+		values = map[string]string{
+			cassetteOrganizationId: orgId,
+			cassetteVaultId:        vaultId,
+			cassetteUserId:         os.Getenv("VGS_CASSETTE_USER_ID"),
+			cassetteRevokeUserId:   os.Getenv("VGS_CASSETTE_REVOKE_USER_ID"),
+		}
+	default:
+		t.Fatalf("unknown %s %q", cassetteModeEnv, mode)
+	}
+
+	if values[cassetteUserId] == "" && os.Getenv(cassetteModeEnv) != "" {
+		t.Fatal("VGS_CASSETTE_USER_ID is required to record")
+	}
+
+	if os.Getenv(cassetteModeEnv) != "" {
+		cfg.WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+			recorder = cassette.NewRecorder(next)
+			return recorder
+		})
+	}
+
+	cli, err := New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	if recorder != nil {
+		for k, v := range values {
+			recorder.SetValue(k, v)
+		}
+		t.Cleanup(func() {
+			if t.Failed() {
+				return
+			}
+			assert.Nil(t, recorder.Save(path))
+		})
+	}
+
+	return cli, values
+}
+
+func TestAccountsAPICassette(t *testing.T) {
+	cli, values := newCassetteClientForTesting(t, "accounts_api")
+	organizationId := values[cassetteOrganizationId]
+	vaultIdentifier := values[cassetteVaultId]
+
+	orgs, _, _, err := cli.ListOrganizations(ctx, "")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, orgs)
+
+	org, _, err := cli.GetOrganization(ctx, organizationId)
+	assert.Nil(t, err)
+	assert.Equal(t, organizationId, org.Id)
+
+	users, _, _, err := cli.ListUsers(ctx, organizationId, "")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, users)
+	for _, u := range users {
+		assert.NotEmpty(t, u.Id)
+		assert.NotEmpty(t, u.Email)
+	}
+
+	_, _, _, err = cli.ListInvites(ctx, organizationId, "")
+	assert.Nil(t, err)
+
+	vaults, _, _, err := cli.ListVaults(ctx, "")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, vaults)
+
+	vault, _, err := cli.GetVault(ctx, vaultIdentifier)
+	assert.Nil(t, err)
+	assert.Equal(t, vaultIdentifier, vault.Id)
+
+	members, _, _, err := cli.ListVaultUsers(ctx, vaultIdentifier, "")
+	assert.Nil(t, err)
+
+	// Write the member's current role back, so recording against VGS leaves access unchanged.
+	role := ""
+	for _, m := range members {
+		if m.Attributes.Id == values[cassetteUserId] {
+			role = m.Attributes.Role
+		}
+	}
+	if !assert.NotEmpty(t, role, "user %s is not a member of vault %s", values[cassetteUserId], vaultIdentifier) {
+		return
+	}
+
+	_, err = cli.UpdateUserAccessVault(ctx, vaultIdentifier, values[cassetteUserId], role)
+	assert.Nil(t, err)
+
+	if revokeUserId := values[cassetteRevokeUserId]; revokeUserId != "" {
+		_, err = cli.RevokeUserAccessVault(ctx, vaultIdentifier, revokeUserId)
+		assert.Nil(t, err)
+	}
+}
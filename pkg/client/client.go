@@ -10,26 +10,37 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 )
 
 type (
 	VGSClient struct {
 		httpClient      *uhttp.BaseHttpClient
-		token           *JWT
+		tokens          *tokenManager
+		retry           retryPolicy
+		clientId        string
 		serviceEndpoint string
-		organizationId  string
+		allowInsecure   bool
+		organizationIds []string
 		vaultId         string
 	}
 
 	Config struct {
 		serviceAccountClientId     string
 		serviceAccountClientSecret string
-		organizationId             string
+		organizationIds            []string
 		vaultId                    string
+		authRealmURL               string
+		accountsAPIURL             string
+		allowInsecureEndpoints     bool
+		wrapTransport              func(http.RoundTripper) http.RoundTripper
 	}
 )
 
@@ -38,10 +49,18 @@ const (
 	ServiceAccountClientSecretName = "service-account-client-secret"
 	OrganizationId                 = "organization-id"
 	VaultId                        = "vault"
+	AuthRealmURL                   = "auth-realm-url"
+	AccountsAPIURL                 = "accounts-api-url"
+	AllowInsecureEndpoints         = "allow-insecure-endpoints"
+	InviteStatuses                 = "invite-statuses"
+	RevokePreviousSecrets          = "revoke-previous-secrets"
+	AllowSelfRotation              = "allow-self-rotation"
+	RevokeReplacedVaultCredentials = "revoke-replaced-vault-credentials"
 	serviceAccountClient           = "serviceAccountClientId"
 	serviceAccountClientSecret     = "serviceAccountClientSecret"
-	organization                   = "organizationId"
 	vault                          = "vaultId"
+	authRealm                      = "authRealmURL"
+	accountsAPI                    = "accountsAPIURL"
 	empty                          = ""
 )
 
@@ -55,8 +74,16 @@ func (c *Config) WithServiceAccountClientSecret(sAccSec string) *Config {
 	return c
 }
 
-func (c *Config) WithOrganizationId(orgId string) *Config {
-	c.organizationId = orgId
+// WithOrganizationIds limits the client to the given organizations. Without any, every organization the service
+// account can see is synced.
+func (c *Config) WithOrganizationIds(orgIds ...string) *Config {
+	c.organizationIds = nil
+	for _, orgId := range orgIds {
+		orgId = strings.TrimSpace(orgId)
+		if orgId != "" && !slices.Contains(c.organizationIds, orgId) {
+			c.organizationIds = append(c.organizationIds, orgId)
+		}
+	}
 	return c
 }
 
@@ -65,16 +92,48 @@ func (c *Config) WithVaultId(vId string) *Config {
 	return c
 }
 
+// WithAuthRealmURL overrides the Keycloak realm used to issue access tokens.
+func (c *Config) WithAuthRealmURL(realmURL string) *Config {
+	c.authRealmURL = realmURL
+	return c
+}
+
+// WithAccountsAPIURL overrides the base URL of the Accounts API.
+func (c *Config) WithAccountsAPIURL(apiURL string) *Config {
+	c.accountsAPIURL = apiURL
+	return c
+}
+
+// WithAllowInsecureEndpoints permits plain HTTP endpoint overrides. Only meant for tests and local stand-in servers.
+func (c *Config) WithAllowInsecureEndpoints(allow bool) *Config {
+	c.allowInsecureEndpoints = allow
+	return c
+}
+
+// WithTransportWrapper wraps the transport of the HTTP client, e.g. to record or replay exchanges in tests.
+func (c *Config) WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) *Config {
+	c.wrapTransport = wrap
+	return c
+}
+
 func (c *Config) getFieldValue(fieldName string) string {
 	switch fieldName {
 	case serviceAccountClient:
 		return c.serviceAccountClientId
 	case serviceAccountClientSecret:
 		return c.serviceAccountClientSecret
-	case organization:
-		return c.organizationId
 	case vault:
 		return c.vaultId
+	case authRealm:
+		if c.authRealmURL == empty {
+			return DefaultAuthRealmURL
+		}
+		return c.authRealmURL
+	case accountsAPI:
+		if c.accountsAPIURL == empty {
+			return DefaultAccountsAPIURL
+		}
+		return c.accountsAPIURL
 	}
 
 	return empty
@@ -114,6 +173,17 @@ func WithJSONBodyV2(body interface{}) uhttp.RequestOption {
 	}
 }
 
+// withOptionalJSONResponse decodes a JSON response body into response, tolerating empty bodies such as 204 No Content.
+func withOptionalJSONResponse(response interface{}) uhttp.DoOption {
+	return func(resp *uhttp.WrapperResponse) error {
+		if len(resp.Body) == 0 {
+			return nil
+		}
+
+		return uhttp.WithJSONResponse(response)(resp)
+	}
+}
+
 func WithContentTypeFormHeader() uhttp.RequestOption {
 	return func() (io.ReadWriter, map[string]string, error) {
 		return nil, map[string]string{
@@ -153,394 +223,625 @@ func WithSetBasicAuthHeader(username, password string) uhttp.RequestOption {
 
 func New(ctx context.Context, cfg Config) (*VGSClient, error) {
 	var (
-		jwt          = &JWT{}
 		clientId     = cfg.getFieldValue(serviceAccountClient)
 		clientSecret = cfg.getFieldValue(serviceAccountClientSecret)
-		orgId        = cfg.getFieldValue(organization)
 		vaultId      = cfg.getFieldValue(vault)
 	)
-	uri, err := url.Parse("https://auth.verygoodsecurity.com/auth/realms/vgs/protocol/openid-connect/token")
+	realmURL, err := parseEndpoint(cfg.getFieldValue(authRealm), cfg.allowInsecureEndpoints)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid %s: %w", AuthRealmURL, err)
 	}
 
-	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	apiURL, err := parseEndpoint(cfg.getFieldValue(accountsAPI), cfg.allowInsecureEndpoints)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid %s: %w", AccountsAPIURL, err)
 	}
 
-	cli := uhttp.NewBaseHttpClient(httpClient)
-	req, err := cli.NewRequest(ctx,
-		http.MethodPost,
-		uri,
-		uhttp.WithAcceptJSONHeader(),
-		WithBody(`grant_type=client_credentials`),
-		WithSetBasicAuthHeader(clientId, clientSecret),
-	)
+	uri := realmURL.JoinPath("protocol", "openid-connect", "token")
+
+	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := cli.Do(req, uhttp.WithJSONResponse(&jwt))
+	if cfg.wrapTransport != nil {
+		httpClient.Transport = cfg.wrapTransport(httpClient.Transport)
+	}
+
+	// Responses are never cached: provisioning reads the current membership before deciding what to write, and a
+	// cached GET would make it act on state that has since changed.
+	cacheCtx := context.WithValue(ctx, uhttp.ContextKey{}, uhttp.CacheConfig{DisableCache: true})
+	cli, err := uhttp.NewBaseHttpClientWithContext(cacheCtx, httpClient)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("token is not valid")
+	tokens := newTokenManager(cli, uri, clientId, clientSecret)
+	// Fetch the first token eagerly so bad credentials fail at startup.
+	_, err = tokens.Token(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	vc := VGSClient{
-		httpClient: cli,
-		token: &JWT{
-			AccessToken:      jwt.AccessToken,
-			ExpiresIn:        jwt.ExpiresIn,
-			RefreshExpiresIn: jwt.RefreshExpiresIn,
-			Scope:            jwt.Scope,
-			TokenType:        jwt.TokenType,
-			NotBeforePolicy:  jwt.NotBeforePolicy,
-		},
-		serviceEndpoint: "https://accounts.apps.verygoodsecurity.com",
-		organizationId:  orgId,
+		httpClient:      cli,
+		tokens:          tokens,
+		retry:           defaultRetryPolicy,
+		clientId:        clientId,
+		serviceEndpoint: apiURL.String(),
+		allowInsecure:   cfg.allowInsecureEndpoints,
+		organizationIds: cfg.organizationIds,
 		vaultId:         vaultId,
 	}
 
 	return &vc, nil
 }
 
-func (v *VGSClient) GetToken() string {
-	return v.token.AccessToken
+// GetToken returns a valid access token, refreshing it first if it is about to expire.
+func (v *VGSClient) GetToken(ctx context.Context) (string, error) {
+	jwt, err := v.tokens.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.AccessToken, nil
 }
 
-func (v *VGSClient) GetOrganizationId() string {
-	return v.organizationId
+// GetScopes returns the scopes granted to the current access token.
+func (v *VGSClient) GetScopes(ctx context.Context) (Scopes, error) {
+	jwt, err := v.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseScopes(jwt.Scope), nil
 }
 
-func (v *VGSClient) GetVaultId() string {
-	return v.vaultId
+// requireScope fails if the current access token was not granted the given scope.
+func (v *VGSClient) requireScope(ctx context.Context, scope string) error {
+	scopes, err := v.GetScopes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !scopes.Has(scope) {
+		return &MissingScopeError{Scope: scope}
+	}
+
+	return nil
 }
 
-func (v *VGSClient) ListOrganizations(ctx context.Context) ([]Organization, error) {
+// doRequest sends an authenticated request to the Accounts API and decodes the JSON response into response,
+// unless it is nil. Idempotent requests that fail with a network error or a gateway error are retried with
+// backoff according to the client's retry policy. Failed responses are returned as *APIError.
+func (v *VGSClient) doRequest(
+	ctx context.Context,
+	method string,
+	uri *url.URL,
+	response interface{},
+	options ...uhttp.RequestOption,
+) (*http.Response, *v2.RateLimitDescription, error) {
+	l := ctxzap.Extract(ctx)
+	for attempt := 1; ; attempt++ {
+		resp, rateLimit, err := v.doAuthenticatedRequest(ctx, method, uri, response, options...)
+		if attempt >= v.retry.maxAttempts || !v.retry.shouldRetry(ctx, method, resp, err) {
+			if attempt > 1 {
+				l.Info("baton-vgs: request finished after retries",
+					zap.String("method", method),
+					zap.String("url", uri.String()),
+					zap.Int("attempts", attempt),
+					zap.Bool("success", err == nil),
+				)
+			}
+			return resp, rateLimit, err
+		}
+
+		delay := v.retry.backoff(attempt)
+		fields := []zap.Field{
+			zap.String("method", method),
+			zap.String("url", uri.String()),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", v.retry.maxAttempts),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		}
+		if resp != nil {
+			fields = append(fields, zap.Int("status_code", resp.StatusCode))
+		}
+		l.Warn("baton-vgs: transient request failure, retrying", fields...)
+
+		if waitErr := wait(ctx, delay); waitErr != nil {
+			return resp, rateLimit, errors.Join(err, waitErr)
+		}
+	}
+}
+
+// doAuthenticatedRequest sends a single request with the current bearer token. If the API rejects the token
+// it is dropped and the request is sent once more with a fresh one. Rate limit data is parsed from every response.
+func (v *VGSClient) doAuthenticatedRequest(
+	ctx context.Context,
+	method string,
+	uri *url.URL,
+	response interface{},
+	options ...uhttp.RequestOption,
+) (*http.Response, *v2.RateLimitDescription, error) {
 	var (
-		organizations        []Organization
-		organizationsAPIData organizationsAPIData
+		resp      *http.Response
+		rateLimit *v2.RateLimitDescription
+		err       error
 	)
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "organizations")
-	if err != nil {
-		return nil, err
+	for attempt := 0; attempt < 2; attempt++ {
+		var token string
+		token, err = v.GetToken(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var req *http.Request
+		req, err = v.httpClient.NewRequest(ctx,
+			method,
+			uri,
+			append([]uhttp.RequestOption{
+				WithAcceptVndJSONHeader(),
+				WithAuthorizationBearerHeader(token),
+			}, options...)...,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rateLimit = &v2.RateLimitDescription{}
+		doOptions := []uhttp.DoOption{withRateLimitData(rateLimit)}
+		if response != nil {
+			doOptions = append(doOptions, withOptionalJSONResponse(response))
+		}
+
+		resp, err = v.httpClient.Do(req, doOptions...)
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			break
+		}
+
+		ctxzap.Extract(ctx).Debug("baton-vgs: access token rejected, refreshing", zap.String("url", uri.String()))
+		v.tokens.Invalidate(token)
 	}
 
-	uri, err := url.Parse(strUrl)
-	if err != nil {
-		return nil, err
+	if resp != nil && resp.StatusCode >= http.StatusMultipleChoices {
+		apiErr := newAPIError(resp, err)
+		apiErr.RateLimit = rateLimit
+		return resp, rateLimit, apiErr
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodGet,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-	)
+	return resp, rateLimit, err
+}
+
+// GetOrganizationIds returns the configured organizations, or nil when every visible organization is synced.
+func (v *VGSClient) GetOrganizationIds() []string {
+	return slices.Clone(v.organizationIds)
+}
+
+// IncludesOrganization reports whether the organization is synced.
+func (v *VGSClient) IncludesOrganization(orgId string) bool {
+	return len(v.organizationIds) == 0 || slices.Contains(v.organizationIds, orgId)
+}
+
+func (v *VGSClient) GetVaultId() string {
+	return v.vaultId
+}
+
+// ListOrganizations
+// Read all organizations the service account has access to.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/organizations/paths/~1organizations/get
+func (v *VGSClient) ListOrganizations(ctx context.Context, cursor string) ([]Organization, string, *v2.RateLimitDescription, error) {
+	var organizations []Organization
+	data, next, rateLimit, err := listJSONAPI[organizationAPI](ctx, v, cursor, []string{"organizations"})
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
 	}
 
-	resp, err := v.httpClient.Do(req, uhttp.WithJSONResponse(&organizationsAPIData))
-	if err != nil {
-		return nil, err
+	for _, org := range data {
+		organizations = append(organizations, org.toOrganization())
 	}
 
-	defer resp.Body.Close()
-	for _, org := range organizationsAPIData.Data {
-		organizations = append(organizations, Organization{
-			Id:        org.Id,
-			Name:      org.Attributes.Name,
-			State:     org.Attributes.State,
-			CreatedAt: org.Attributes.CreatedAt,
-			UpdatedAt: org.Attributes.UpdatedAt,
-		})
+	return organizations, next, rateLimit, nil
+}
+
+// GetOrganization
+// Read a single organization the service account has access to.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/organizations/paths/~1organizations~1{organizationId}/get
+func (v *VGSClient) GetOrganization(ctx context.Context, orgId string) (*Organization, *v2.RateLimitDescription, error) {
+	doc, rateLimit, err := getJSONAPI[organizationAPI](ctx, v, []string{"organizations", orgId})
+	if err != nil {
+		return nil, rateLimit, err
 	}
 
-	return organizations, nil
+	org := doc.Data.toOrganization()
+	return &org, rateLimit, nil
 }
 
 // ListUsers
 // Read all organizations users. Retrieves list of all users linked to an organization. NOTE: This endpoint does not return pending invitations.
 // https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1organizations~1{organizationId}~1members/get
-func (v *VGSClient) ListUsers(ctx context.Context, orgId, vaultId string) ([]OrganizationUser, error) {
-	var (
-		users                    []OrganizationUser
-		organizationUsersAPIData organizationUsersAPIData
-	)
-	if !strings.Contains(v.token.Scope, "organization-users:read") {
-		return nil, fmt.Errorf("organization-users:read scope not found")
+func (v *VGSClient) ListUsers(ctx context.Context, orgId, cursor string) ([]OrganizationUser, string, *v2.RateLimitDescription, error) {
+	var users []OrganizationUser
+	err := v.requireScope(ctx, ScopeOrganizationUsersRead)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "organizations", orgId, "members")
+	data, next, rateLimit, err := listJSONAPI[organizationUserAPI](ctx, v, cursor, []string{"organizations", orgId, "members"})
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
+	}
+
+	for _, userAPI := range data {
+		users = append(users, userAPI.toOrganizationUser())
 	}
 
-	uri, err := url.Parse(strUrl)
+	return users, next, rateLimit, nil
+}
+
+// GetOrganizationMember
+// Read a single member of an organization. Answers 404 if the user is not a member.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1organizations~1{organizationId}~1members~1{userId}/get
+func (v *VGSClient) GetOrganizationMember(ctx context.Context, orgId, userId string) (*OrganizationUser, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersRead)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodGet,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-	)
+	doc, rateLimit, err := getJSONAPI[organizationUserAPI](ctx, v, []string{"organizations", orgId, "members", userId})
 	if err != nil {
-		return nil, err
+		return nil, rateLimit, err
 	}
 
-	resp, err := v.httpClient.Do(req, uhttp.WithJSONResponse(&organizationUsersAPIData))
+	user := doc.Data.toOrganizationUser()
+	return &user, rateLimit, nil
+}
+
+// UpdateUserRoleOrganization
+// Change the role of an organization member. Roles are sent upper-cased, the way the members endpoint reports them.
+// Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1organizations~1{organizationId}~1members~1{userId}/put
+func (v *VGSClient) UpdateUserRoleOrganization(ctx context.Context, orgId, userId, role string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	for _, userAPI := range organizationUsersAPIData.Data {
-		users = append(users, OrganizationUser{
-			Id:        userAPI.Id,
-			Name:      userAPI.Attributes.Name,
-			Email:     userAPI.Attributes.EmailAddress,
-			CreatedAt: userAPI.Attributes.CreatedAt,
-			UpdatedAt: userAPI.Attributes.UpdatedAt,
-		})
+	return putJSONAPI(ctx, v,
+		[]string{"organizations", orgId, "members", userId},
+		newRequestDocument("", organizationMemberAttributes{Role: strings.ToUpper(role)}),
+	)
+}
+
+// RemoveUserOrganization
+// Remove a member from an organization. Their vault memberships are not documented to go with it, so callers revoke
+// them first. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1organizations~1{organizationId}~1members~1{userId}/delete
+func (v *VGSClient) RemoveUserOrganization(ctx context.Context, orgId, userId string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
+	if err != nil {
+		return nil, err
 	}
 
-	return users, nil
+	return deleteJSONAPI(ctx, v, []string{"organizations", orgId, "members", userId})
 }
 
-// ListUserInvites
-// Get user invitations to an organization. Returns list of user invitations to an organization.
+// ListInvites
+// Get user invitations to an organization. Returns every invitation regardless of its status.
 // https://www.verygoodsecurity.com/docs/accounts/api/#tag/invites/paths/~1organizations~1{organizationId}~1invites/get
-func (v *VGSClient) ListUserInvites(ctx context.Context, orgId string) ([]OrganizationUser, error) {
-	var (
-		userInvites                []OrganizationUser
-		organizationInvitesAPIData organizationInvitesAPIData
-	)
-	if !strings.Contains(v.token.Scope, "organization-users:read") {
-		return nil, fmt.Errorf("organization-users:read scope not found")
+func (v *VGSClient) ListInvites(ctx context.Context, orgId, cursor string) ([]Invite, string, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersRead)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "organizations", orgId, "invites")
+	data, next, rateLimit, err := listJSONAPI[organizationInviteAPI](ctx, v, cursor, []string{"organizations", orgId, "invites"})
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
 	}
 
-	uri, err := url.Parse(strUrl)
+	invites := make([]Invite, 0, len(data))
+	for _, inviteAPI := range data {
+		invites = append(invites, inviteAPI.toInvite())
+	}
+
+	return invites, next, rateLimit, nil
+}
+
+// CreateInvite
+// Invite a user to an organization with an organization role and, optionally, roles on the organization's vaults.
+// The user becomes a member once they accept. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/invites/paths/~1organizations~1{organizationId}~1invites/post
+func (v *VGSClient) CreateInvite(ctx context.Context, orgId, email, role string, vaults []InviteVault) (*Invite, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	attributes := organizationInviteRequestAttributes{
+		UserEmail: email,
+		Role:      strings.ToUpper(role),
+	}
+	for _, vault := range vaults {
+		attributes.Vaults = append(attributes.Vaults, inviteVaultAttributes{Identifier: vault.Id, Role: vault.Role})
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodGet,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
+	doc, rateLimit, err := postJSONAPI[organizationInviteRequestAttributes, organizationInviteAPI](ctx, v,
+		[]string{"organizations", orgId, "invites"},
+		newRequestDocument("invites", attributes),
 	)
 	if err != nil {
-		return nil, err
+		return nil, rateLimit, err
 	}
 
-	resp, err := v.httpClient.Do(req, uhttp.WithJSONResponse(&organizationInvitesAPIData))
+	invite := doc.Data.toInvite()
+	return &invite, rateLimit, nil
+}
+
+// CancelInvite
+// Cancel an invitation that has not been accepted yet. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/invites/paths/~1organizations~1{organizationId}~1invites~1{inviteId}/delete
+func (v *VGSClient) CancelInvite(ctx context.Context, orgId, inviteId string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	for _, inviteAPI := range organizationInvitesAPIData.Data {
-		if inviteAPI.Attributes.InviteStatus != "EXPIRED" {
-			userInvites = append(userInvites, OrganizationUser{
-				Id:        inviteAPI.Attributes.InviteId,
-				Name:      inviteAPI.Attributes.InvitedBy,
-				Email:     inviteAPI.Attributes.UserEmail,
-				CreatedAt: inviteAPI.Attributes.CreatedAt,
-			})
-		}
-	}
-
-	return userInvites, nil
+	return deleteJSONAPI(ctx, v, []string{"organizations", orgId, "invites", inviteId})
 }
 
-// ListVaultUsers
-// Read all vault users. Retrieves list of all users linked to a vault.
-// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members/get
-func (v *VGSClient) ListVaultUsers(ctx context.Context, vaultId string) ([]vaultUserAPI, error) {
-	var vaultUsersAPIData vaultUsersAPIData
-	if !strings.Contains(v.token.Scope, "organization-users:read") {
-		return nil, fmt.Errorf("organization-users:read scope not found")
+// ListAuditLogs
+// Read the audit log of an organization, oldest entries first. When since is set, only entries that occurred at or
+// after it are returned. Requires audit-logs:read scope.
+// Not in the Accounts API reference: the path, the since filter and the occurred_at sort follow the documented list
+// endpoints and have only been exercised against pkg/vgsfake.
+func (v *VGSClient) ListAuditLogs(ctx context.Context, orgId string, since time.Time, cursor string) ([]AuditLogEntry, string, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeAuditLogsRead)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "vaults", vaultId, "members")
-	if err != nil {
-		return nil, err
+	// The event cursor resumes after the last entry read, so entries are requested oldest first rather than in the
+	// API's default order.
+	options := []queryOption{withSort("occurred_at")}
+	if !since.IsZero() {
+		options = append(options, withFilter("since", since.UTC().Format(time.RFC3339)))
 	}
 
-	uri, err := url.Parse(strUrl)
+	data, next, rateLimit, err := listJSONAPI[auditLogAPI](ctx, v, cursor, []string{"organizations", orgId, "audit-logs"}, options...)
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodGet,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-	)
+	entries := make([]AuditLogEntry, 0, len(data))
+	for _, a := range data {
+		entries = append(entries, a.toAuditLogEntry())
+	}
+
+	return entries, next, rateLimit, nil
+}
+
+// ListServiceAccounts
+// Read the service accounts of an organization along with their scopes. Requires service-accounts:read scope.
+// Not in the Accounts API reference: the path and attributes follow the documented organization endpoints and have
+// only been exercised against pkg/vgsfake.
+func (v *VGSClient) ListServiceAccounts(ctx context.Context, orgId, cursor string) ([]ServiceAccount, string, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeServiceAccountsRead)
 	if err != nil {
-		return nil, err
+		return nil, "", nil, err
 	}
 
-	resp, err := v.httpClient.Do(req, uhttp.WithJSONResponse(&vaultUsersAPIData))
+	data, next, rateLimit, err := listJSONAPI[serviceAccountAPI](ctx, v, cursor, []string{"organizations", orgId, "service-accounts"})
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
+	}
+
+	serviceAccounts := make([]ServiceAccount, 0, len(data))
+	for _, sa := range data {
+		serviceAccounts = append(serviceAccounts, sa.toServiceAccount())
 	}
 
-	defer resp.Body.Close()
-	return vaultUsersAPIData.Data, nil
+	return serviceAccounts, next, rateLimit, nil
 }
 
-func (v *VGSClient) ListVaults(ctx context.Context) ([]Vault, error) {
-	var (
-		organizationVaults        []Vault
-		organizationVaultsAPIData organizationVaultsAPIData
-	)
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "vaults")
+// RotateServiceAccountSecret
+// Issue a new client secret for a service account of an organization. Earlier secrets keep working unless
+// revokePrevious is set. Requires service-accounts:write scope.
+// Not in the Accounts API reference, like ListServiceAccounts; the secrets path and the revoke_previous attribute are
+// unverified against VGS.
+func (v *VGSClient) RotateServiceAccountSecret(ctx context.Context, orgId, clientId string, revokePrevious bool) (*ServiceAccountSecret, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeServiceAccountsWrite)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	uri, err := url.Parse(strUrl)
+	doc, rateLimit, err := postJSONAPI[serviceAccountSecretRequestAttributes, serviceAccountSecretAPI](ctx, v,
+		[]string{"organizations", orgId, "service-accounts", clientId, "secrets"},
+		newRequestDocument("service_account_secrets", serviceAccountSecretRequestAttributes{RevokePrevious: revokePrevious}),
+	)
 	if err != nil {
-		return nil, err
+		return nil, rateLimit, err
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodGet,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-	)
+	secret := doc.Data.toServiceAccountSecret()
+	return &secret, rateLimit, nil
+}
+
+// GetClientId returns the client id of the service account the client authenticates as.
+func (v *VGSClient) GetClientId() string {
+	return v.clientId
+}
+
+// ListVaultUsers
+// Read all vault users. Retrieves list of all users linked to a vault.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members/get
+func (v *VGSClient) ListVaultUsers(ctx context.Context, vaultId, cursor string) ([]vaultUserAPI, string, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersRead)
 	if err != nil {
-		return nil, err
+		return nil, "", nil, err
 	}
 
-	resp, err := v.httpClient.Do(req, uhttp.WithJSONResponse(&organizationVaultsAPIData))
+	return listJSONAPI[vaultUserAPI](ctx, v, cursor, []string{"vaults", vaultId, "members"})
+}
+
+// ListVaults
+// Read all vaults the service account has access to.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/vaults/paths/~1vaults/get
+func (v *VGSClient) ListVaults(ctx context.Context, cursor string) ([]Vault, string, *v2.RateLimitDescription, error) {
+	var organizationVaults []Vault
+	data, next, rateLimit, err := listJSONAPI[organizationVaultAPI](ctx, v, cursor, []string{"vaults"})
 	if err != nil {
-		return nil, err
+		return nil, "", rateLimit, err
 	}
 
-	defer resp.Body.Close()
-	for _, vault := range organizationVaultsAPIData.Data {
-		organizationVaults = append(organizationVaults, Vault{
-			Id:          vault.Attributes.Identifier,
-			Name:        vault.Attributes.Name,
-			Environment: vault.Attributes.Environment,
-			CreatedAt:   vault.Attributes.CreatedAt,
-			UpdatedAt:   vault.Attributes.UpdatedAt,
-		})
+	for _, vault := range data {
+		organizationVaults = append(organizationVaults, vault.toVault())
 	}
 
-	return organizationVaults, nil
+	return organizationVaults, next, rateLimit, nil
 }
 
-// UpdateVault
-// Update user access to vault. Requires organization-users:write scope.
-// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members~1{userId}/put
-func (v *VGSClient) UpdateUserAccessVault(ctx context.Context, vaultIdentifier, userId, role string) error {
-	var (
-		body    Body
-		payload = []byte(fmt.Sprintf(`{"data":{"attributes":{"role":"%s"}}}`, role))
-	)
-	if !strings.Contains(v.token.Scope, "organization-users:write") {
-		return fmt.Errorf("organization-users:write scope not found")
+// GetVault
+// Read a single vault the service account has access to.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/vaults/paths/~1vaults~1{vaultIdentifier}/get
+func (v *VGSClient) GetVault(ctx context.Context, vaultId string) (*Vault, *v2.RateLimitDescription, error) {
+	doc, rateLimit, err := getJSONAPI[organizationVaultAPI](ctx, v, []string{"vaults", vaultId})
+	if err != nil {
+		return nil, rateLimit, err
 	}
 
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "vaults", vaultIdentifier, "members", userId)
+	vault := doc.Data.toVault()
+	return &vault, rateLimit, nil
+}
+
+// ListVaultCredentials
+// Read the access credentials of a vault's HTTP API from the vault management API the vault advertises.
+// Requires credentials:read scope.
+// The vault management API is not covered by the Accounts API reference; the credentials path and attributes have
+// only been exercised against pkg/vgsfake.
+func (v *VGSClient) ListVaultCredentials(ctx context.Context, vault *Vault, cursor string) ([]VaultCredential, string, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeCredentialsRead)
 	if err != nil {
-		return err
+		return nil, "", nil, err
 	}
 
-	uri, err := url.Parse(strUrl)
+	base, err := v.vaultManagementEndpoint(vault)
 	if err != nil {
-		return err
+		return nil, "", nil, err
 	}
 
-	err = json.Unmarshal(payload, &body)
+	data, next, rateLimit, err := listJSONAPIAt[vaultCredentialAPI](ctx, v, base, cursor, []string{"vaults", vault.Id, "credentials"})
 	if err != nil {
-		return err
+		return nil, "", rateLimit, err
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodPut,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-		WithJSONBodyV2(body),
-	)
+	credentials := make([]VaultCredential, 0, len(data))
+	for _, c := range data {
+		credentials = append(credentials, c.toVaultCredential(vault.Id))
+	}
 
+	return credentials, next, rateLimit, nil
+}
+
+// CreateVaultCredential
+// Issue a new access credential for a vault's HTTP API through the vault management API the vault advertises. The
+// returned credential carries its secret. Requires credentials:write scope.
+// Unverified against VGS, see ListVaultCredentials.
+func (v *VGSClient) CreateVaultCredential(ctx context.Context, vault *Vault) (*VaultCredential, *v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeCredentialsWrite)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	resp, err := v.httpClient.Do(req)
+	base, err := v.vaultManagementEndpoint(vault)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusNoContent {
-		return errors.New("user details not updated")
+	doc, rateLimit, err := postJSONAPIAt[vaultCredentialRequestAttributes, vaultCredentialAPI](ctx, v, base,
+		[]string{"vaults", vault.Id, "credentials"},
+		newRequestDocument("credentials", vaultCredentialRequestAttributes{}),
+	)
+	if err != nil {
+		return nil, rateLimit, err
 	}
 
-	return nil
+	credential := doc.Data.toVaultCredential(vault.Id)
+	return &credential, rateLimit, nil
 }
 
-// RevokeUserAccessVault
-// Revoke user access to vault. Requires organization-users:write scope.
-// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members~1{userId}/delete
-func (v *VGSClient) RevokeUserAccessVault(ctx context.Context, vaultIdentifier, userId string) error {
-	if !strings.Contains(v.token.Scope, "organization-users:write") {
-		return fmt.Errorf("organization-users:write scope not found")
+// DeleteVaultCredential
+// Revoke an access credential of a vault's HTTP API. Requires credentials:write scope.
+// Unverified against VGS, see ListVaultCredentials.
+func (v *VGSClient) DeleteVaultCredential(ctx context.Context, vault *Vault, credentialId string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeCredentialsWrite)
+	if err != nil {
+		return nil, err
 	}
 
-	strUrl, err := url.JoinPath(v.serviceEndpoint, "vaults", vaultIdentifier, "members", userId)
+	base, err := v.vaultManagementEndpoint(vault)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	uri, err := url.Parse(strUrl)
+	return deleteJSONAPIAt(ctx, v, base, []string{"vaults", vault.Id, "credentials", credentialId})
+}
+
+// vaultManagementEndpoint validates the vault management API link of a vault. The link comes from the Accounts API
+// and the access token is sent to it, so it is held to the same rules as the configured endpoints.
+func (v *VGSClient) vaultManagementEndpoint(vault *Vault) (string, error) {
+	if vault.ManagementAPIURL == "" {
+		return "", fmt.Errorf("vault %s does not advertise a vault management API", vault.Id)
+	}
+
+	uri, err := parseEndpoint(vault.ManagementAPIURL, v.allowInsecure)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("vault %s advertises an invalid vault management API: %w", vault.Id, err)
 	}
 
-	req, err := v.httpClient.NewRequest(ctx,
-		http.MethodDelete,
-		uri,
-		WithAcceptVndJSONHeader(),
-		WithContentTypeVndHeader(),
-		WithAuthorizationBearerHeader(v.GetToken()),
-	)
+	return uri.String(), nil
+}
 
+// AddUserAccessVault
+// Add an organization member to a vault with the given role. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members/post
+func (v *VGSClient) AddUserAccessVault(ctx context.Context, vaultIdentifier, userId, role string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	resp, err := v.httpClient.Do(req)
+	_, rateLimit, err := postJSONAPI[vaultMemberCreateAttributes, vaultUserAPI](ctx, v,
+		[]string{"vaults", vaultIdentifier, "members"},
+		newRequestDocument("vault_members", vaultMemberCreateAttributes{UserId: userId, Role: role}),
+	)
+	return rateLimit, err
+}
+
+// UpdateUserAccessVault
+// Update user access to vault. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members~1{userId}/put
+func (v *VGSClient) UpdateUserAccessVault(ctx context.Context, vaultIdentifier, userId, role string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusNoContent {
-		return errors.New("user details not updated")
+	return putJSONAPI(ctx, v,
+		[]string{"vaults", vaultIdentifier, "members", userId},
+		newRequestDocument("", vaultMemberAttributes{Role: role}),
+	)
+}
+
+// RevokeUserAccessVault
+// Revoke user access to vault. Requires organization-users:write scope.
+// https://www.verygoodsecurity.com/docs/accounts/api/#tag/users/paths/~1vaults~1{vaultIdentifier}~1members~1{userId}/delete
+func (v *VGSClient) RevokeUserAccessVault(ctx context.Context, vaultIdentifier, userId string) (*v2.RateLimitDescription, error) {
+	err := v.requireScope(ctx, ScopeOrganizationUsersWrite)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return deleteJSONAPI(ctx, v, []string{"vaults", vaultIdentifier, "members", userId})
 }
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/conductorone/baton-vgs/pkg/vgsfake"
+	"github.com/stretchr/testify/assert"
+)
+
+const fixtureForTesting = "../vgsfake/testdata/basic.json"
+
+// newFakeClientForTesting returns a client talking to the fake as the given service account.
+func newFakeClientForTesting(t *testing.T, s *vgsfake.Server, id, secret string) *VGSClient {
+	cfg := Config{}
+	cfg.WithServiceAccountClientId(id).
+		WithServiceAccountClientSecret(secret).
+		WithOrganizationIds("ACorg1").
+		WithAuthRealmURL(s.AuthRealmURL()).
+		WithAccountsAPIURL(s.AccountsAPIURL()).
+		WithAllowInsecureEndpoints(true)
+
+	cli, err := New(ctx, cfg)
+	assert.Nil(t, err)
+
+	return cli
+}
+
+func TestNewRejectsInvalidCredentials(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cfg := Config{}
+	cfg.WithServiceAccountClientId("ACaaa-reader").
+		WithServiceAccountClientSecret("wrong").
+		WithAuthRealmURL(s.AuthRealmURL()).
+		WithAccountsAPIURL(s.AccountsAPIURL()).
+		WithAllowInsecureEndpoints(true)
+
+	_, err := New(ctx, cfg)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestListUsersPaginates(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting, vgsfake.WithMaxPageSize(2))
+	cli := newFakeClientForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	users, next, _, err := cli.ListUsers(ctx, "ACorg1", "")
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "2", next)
+
+	users, next, _, err = cli.ListUsers(ctx, "ACorg1", next)
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "carol@example.com", users[0].Email)
+	assert.Empty(t, next)
+	s.AssertCallCount(t, http.MethodGet, "/organizations/ACorg1/members", 2)
+}
+
+func TestListInvites(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	invites, _, _, err := cli.ListInvites(ctx, "ACorg1", "")
+	assert.Nil(t, err)
+	assert.Len(t, invites, 2)
+	assert.Equal(t, Invite{
+		Id:        "INVpending",
+		Email:     "dave@example.com",
+		InvitedBy: "alice@example.com",
+		Status:    "PENDING",
+		Role:      "MEMBER",
+		Vaults:    []InviteVault{{Id: "tntsandbox", Role: "write"}},
+		CreatedAt: "2024-03-01T12:00:00Z",
+	}, invites[0])
+	assert.Equal(t, "EXPIRED", invites[1].Status)
+}
+
+func TestListServiceAccounts(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACbbb-admin", "admin-secret")
+
+	serviceAccounts, _, _, err := cli.ListServiceAccounts(ctx, "ACorg1", "")
+	assert.Nil(t, err)
+	assert.Len(t, serviceAccounts, 2)
+	assert.Equal(t, ServiceAccount{
+		ClientId:  "ACbbb-admin",
+		Name:      "baton-admin",
+		Scopes:    []string{"organization-users:read", "organization-users:write", "service-accounts:read", "service-accounts:write", "vaults:write", "credentials:read", "credentials:write"},
+		Vaults:    []string{"tntsandbox"},
+		CreatedBy: "alice@example.com",
+		CreatedAt: "2024-01-06T08:00:00Z",
+	}, serviceAccounts[1])
+	assert.Equal(t, "ACbbb-admin", cli.GetClientId())
+}
+
+func TestRotateServiceAccountSecret(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACbbb-admin", "admin-secret")
+
+	secret, _, err := cli.RotateServiceAccountSecret(ctx, "ACorg1", "ACaaa-reader", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "ACaaa-reader", secret.ClientId)
+	assert.NotEmpty(t, secret.ClientSecret)
+	assert.NotEqual(t, "reader-secret", secret.ClientSecret)
+	newFakeClientForTesting(t, s, "ACaaa-reader", "reader-secret")
+	newFakeClientForTesting(t, s, "ACaaa-reader", secret.ClientSecret)
+
+	rotated, _, err := cli.RotateServiceAccountSecret(ctx, "ACorg1", "ACaaa-reader", true)
+	assert.Nil(t, err)
+	assert.True(t, rotated.RevokePrevious)
+	c, _ := s.Client("ACaaa-reader")
+	assert.Equal(t, rotated.ClientSecret, c.Secret)
+	assert.Empty(t, c.PreviousSecrets)
+
+	_, _, err = cli.RotateServiceAccountSecret(ctx, "ACorg1", "ACmissing", false)
+	assert.NotNil(t, err)
+
+	reader := newFakeClientForTesting(t, s, "ACaaa-reader", rotated.ClientSecret)
+	_, _, err = reader.RotateServiceAccountSecret(ctx, "ACorg1", "ACaaa-reader", false)
+	assert.NotNil(t, err)
+}
+
+func TestListVaultCredentials(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACbbb-admin", "admin-secret")
+
+	vault, _, err := cli.GetVault(ctx, "tntsandbox")
+	assert.Nil(t, err)
+	assert.Equal(t, s.VaultManagementURL(), vault.ManagementAPIURL)
+
+	credentials, _, _, err := cli.ListVaultCredentials(ctx, vault, "")
+	assert.Nil(t, err)
+	if assert.Len(t, credentials, 2) {
+		assert.Equal(t, VaultCredential{
+			Id:        "USalice1",
+			VaultId:   "tntsandbox",
+			CreatedBy: "alice@example.com",
+			CreatedAt: "2024-02-01T10:00:00Z",
+		}, credentials[0])
+	}
+
+	issued, _, err := cli.CreateVaultCredential(ctx, vault)
+	assert.Nil(t, err)
+	assert.Equal(t, "tntsandbox", issued.VaultId)
+	assert.NotEmpty(t, issued.Secret)
+
+	_, err = cli.DeleteVaultCredential(ctx, vault, issued.Id)
+	assert.Nil(t, err)
+	_, ok := s.VaultCredential("tntsandbox", issued.Id)
+	assert.False(t, ok)
+
+	// The vault management API receives the access token, so it must use https like the configured endpoints.
+	cli.allowInsecure = false
+	s.ResetCalls()
+	_, _, _, err = cli.ListVaultCredentials(ctx, vault, "")
+	assert.ErrorContains(t, err, "does not use https")
+	_, _, _, err = cli.ListVaultCredentials(ctx, &Vault{Id: "tntsandbox"}, "")
+	assert.NotNil(t, err)
+	assert.Empty(t, s.Calls())
+}
+
+func TestVaultAccessAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACbbb-admin", "admin-secret")
+
+	vault, _, err := cli.GetVault(ctx, "tntsandbox")
+	assert.Nil(t, err)
+	assert.Equal(t, "Sandbox", vault.Name)
+	assert.Equal(t, "ACorg1", vault.OrganizationId)
+
+	_, err = cli.UpdateUserAccessVault(ctx, "tntsandbox", "IDbob", "admin")
+	assert.Nil(t, err)
+	member, _ := s.VaultMember("tntsandbox", "IDbob")
+	assert.Equal(t, "admin", member.Role)
+
+	_, err = cli.RevokeUserAccessVault(ctx, "tntsandbox", "IDbob")
+	assert.Nil(t, err)
+	_, ok := s.VaultMember("tntsandbox", "IDbob")
+	assert.False(t, ok)
+
+	_, err = cli.AddUserAccessVault(ctx, "tntsandbox", "IDbob", "read")
+	assert.Nil(t, err)
+	member, _ = s.VaultMember("tntsandbox", "IDbob")
+	assert.Equal(t, "read", member.Role)
+
+	_, _, err = cli.GetVault(ctx, "tntmissing")
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestReadsBypassResponseCache(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cfg := Config{}
+	cfg.WithServiceAccountClientId("ACbbb-admin").
+		WithServiceAccountClientSecret("admin-secret").
+		WithAuthRealmURL(s.AuthRealmURL()).
+		WithAccountsAPIURL(s.AccountsAPIURL()).
+		WithAllowInsecureEndpoints(true)
+
+	// The SDK caches GET responses for an hour unless told otherwise.
+	cacheCtx := context.WithValue(ctx, uhttp.ContextKey{}, uhttp.CacheConfig{CacheTTL: 3600, CacheMaxSize: 128})
+	cli, err := New(cacheCtx, cfg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	role := func() string {
+		members, _, _, err := cli.ListVaultUsers(ctx, "tntsandbox", "")
+		assert.Nil(t, err)
+		for _, m := range members {
+			if m.Attributes.Id == "IDbob" {
+				return m.Attributes.Role
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, "write", role())
+	_, err = cli.UpdateUserAccessVault(ctx, "tntsandbox", "IDbob", "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", role())
+	s.AssertCallCount(t, http.MethodGet, "/vaults/tntsandbox/members", 2)
+}
+
+func TestWriteRequiresScope(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	_, err := cli.RevokeUserAccessVault(ctx, "tntsandbox", "IDbob")
+	var scopeErr *MissingScopeError
+	if assert.ErrorAs(t, err, &scopeErr) {
+		assert.Equal(t, ScopeOrganizationUsersWrite, scopeErr.Scope)
+	}
+	s.AssertNotCalled(t, http.MethodDelete, "/vaults/tntsandbox/members/IDbob")
+}
+
+func TestExpiredTokenIsRefreshed(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	cli := newFakeClientForTesting(t, s, "ACaaa-reader", "reader-secret")
+	s.ExpireTokens()
+
+	vaults, _, _, err := cli.ListVaults(ctx, "")
+	assert.Nil(t, err)
+	assert.Len(t, vaults, 2)
+	s.AssertCallCount(t, http.MethodPost, vgsfake.TokenPath, 2)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultAuthRealmURL   = "https://auth.verygoodsecurity.com/auth/realms/vgs"
+	DefaultAccountsAPIURL = "https://accounts.apps.verygoodsecurity.com"
+)
+
+// parseEndpoint validates a configured base URL. Only HTTPS endpoints are accepted unless allowInsecure is set,
+// in which case plain HTTP is allowed as well so tests can point the connector at a local stand-in server.
+func parseEndpoint(rawURL string, allowInsecure bool) (*url.URL, error) {
+	uri, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", rawURL)
+	}
+
+	if uri.User != nil || uri.RawQuery != "" || uri.Fragment != "" {
+		return nil, fmt.Errorf("%q must not contain credentials, a query or a fragment", rawURL)
+	}
+
+	switch strings.ToLower(uri.Scheme) {
+	case "https":
+	case "http":
+		if !allowInsecure {
+			return nil, fmt.Errorf("%q does not use https; set %s to allow insecure endpoints", rawURL, AllowInsecureEndpoints)
+		}
+	default:
+		return nil, fmt.Errorf("%q uses unsupported scheme %q", rawURL, uri.Scheme)
+	}
+
+	uri.Path = strings.TrimSuffix(uri.Path, "/")
+
+	return uri, nil
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+var requestIdHeaders = []string{
+	"X-Request-Id",
+	"Vgs-Request-Id",
+	"X-Amzn-Requestid",
+}
+
+// ErrorObject is a single entry of the JSON:API `errors` array.
+type ErrorObject struct {
+	Id     string      `json:"id,omitempty"`
+	Status string      `json:"status,omitempty"`
+	Code   string      `json:"code,omitempty"`
+	Title  string      `json:"title,omitempty"`
+	Detail string      `json:"detail,omitempty"`
+	Source errorSource `json:"source,omitempty"`
+}
+
+type errorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+type errorDocument struct {
+	Errors []ErrorObject `json:"errors,omitempty"`
+	// The token endpoint answers with OAuth 2.0 errors instead of JSON:API ones.
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// APIError is returned when VGS answers a request with a non-success status code.
+// The fields describe the first error object in the response; all of them are kept in Errors.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Title      string
+	Detail     string
+	Pointer    string
+	RequestId  string
+	Errors     []ErrorObject
+	RateLimit  *v2.RateLimitDescription
+	cause      error
+}
+
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "vgs api error: status %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&sb, ", code %s", e.Code)
+	}
+
+	switch {
+	case e.Detail != "":
+		fmt.Fprintf(&sb, ": %s", e.Detail)
+	case e.Title != "":
+		fmt.Fprintf(&sb, ": %s", e.Title)
+	}
+
+	if e.Pointer != "" {
+		fmt.Fprintf(&sb, " (at %s)", e.Pointer)
+	}
+
+	if e.RequestId != "" {
+		fmt.Fprintf(&sb, " [request id %s]", e.RequestId)
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns the transport error reported alongside the response.
+func (e *APIError) Unwrap() error {
+	return e.cause
+}
+
+// MissingScopeError is returned, without sending the request, when the service account lacks the scope a request
+// requires.
+type MissingScopeError struct {
+	Scope string
+}
+
+func (e *MissingScopeError) Error() string {
+	return fmt.Sprintf("%s scope not found", e.Scope)
+}
+
+// newAPIError builds an APIError from a failed response. The response body is left readable.
+func newAPIError(resp *http.Response, cause error) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		cause:      cause,
+	}
+	for _, header := range requestIdHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			apiErr.RequestId = id
+			break
+		}
+	}
+
+	if resp.Body == nil {
+		return apiErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return apiErr
+	}
+
+	var doc errorDocument
+	if json.Unmarshal(body, &doc) != nil {
+		return apiErr
+	}
+
+	apiErr.Errors = doc.Errors
+	if len(doc.Errors) > 0 {
+		first := doc.Errors[0]
+		apiErr.Code = first.Code
+		apiErr.Title = first.Title
+		apiErr.Detail = first.Detail
+		apiErr.Pointer = first.Source.Pointer
+		if apiErr.Pointer == "" {
+			apiErr.Pointer = first.Source.Parameter
+		}
+		if apiErr.RequestId == "" {
+			apiErr.RequestId = first.Id
+		}
+	} else if doc.Error != "" {
+		apiErr.Code = doc.Error
+		apiErr.Detail = doc.ErrorDescription
+	}
+
+	return apiErr
+}
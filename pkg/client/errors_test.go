@@ -0,0 +1,71 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoRequestReturnsAPIError(t *testing.T) {
+	tokenSrv, _ := newTokenServerForTesting(t, 300)
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = io.WriteString(w, `{"errors":[{"status":"409","code":"member-exists","title":"Conflict","detail":"user is already a member","source":{"pointer":"/data/attributes/user_id"}}]}`)
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	cli := &VGSClient{
+		httpClient:      newBaseHttpClientForTesting(t, apiSrv),
+		tokens:          newTokenManagerForTesting(t, tokenSrv),
+		serviceEndpoint: apiSrv.URL,
+	}
+
+	_, err := putJSONAPI(ctx, cli, []string{"vaults", "tnt1", "members", "u1"}, newRequestDocument("", vaultMemberAttributes{Role: "write"}))
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, "member-exists", apiErr.Code)
+	assert.Equal(t, "user is already a member", apiErr.Detail)
+	assert.Equal(t, "/data/attributes/user_id", apiErr.Pointer)
+	assert.Equal(t, "req-123", apiErr.RequestId)
+	assert.Equal(t, "vgs api error: status 409, code member-exists: user is already a member (at /data/attributes/user_id) [request id req-123]", apiErr.Error())
+}
+
+func TestTokenErrorIsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"error":"unauthorized_client","error_description":"Invalid client secret"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := newTokenManagerForTesting(t, srv).Token(ctx)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "unauthorized_client", apiErr.Code)
+	assert.Equal(t, "Invalid client secret", apiErr.Detail)
+}
+
+func TestNewAPIErrorLeavesBodyReadable(t *testing.T) {
+	const body = `{"errors":[{"status":"404","title":"Not Found"}]}`
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	apiErr := newAPIError(resp, nil)
+	assert.Equal(t, "Not Found", apiErr.Title)
+
+	read, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(read))
+}
@@ -21,7 +21,7 @@ var (
 	cfg             = Config{
 		serviceAccountClientId:     clientId,
 		serviceAccountClientSecret: clientSecret,
-		organizationId:             orgId,
+		organizationIds:            []string{orgId},
 		vaultId:                    vaultId,
 	}
 )
@@ -57,7 +57,7 @@ func TestOrganizationResources(t *testing.T) {
 	cfg := Config{
 		serviceAccountClientId:     clientId,
 		serviceAccountClientSecret: clientSecret,
-		organizationId:             orgId,
+		organizationIds:            []string{orgId},
 		vaultId:                    vaultId,
 	}
 	cli, err := getClientForTesting(ctx, cfg)
@@ -167,11 +167,16 @@ func TestVaults(t *testing.T) {
 }
 
 func getRequestForTesting(cli *VGSClient, uri *url.URL) (*http.Request, error) {
+	token, err := cli.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := cli.httpClient.NewRequest(ctx,
 		http.MethodGet,
 		uri,
 		WithAcceptVndJSONHeader(),
-		WithAuthorizationBearerHeader(cli.GetToken()),
+		WithAuthorizationBearerHeader(token),
 	)
 	return req, err
 }
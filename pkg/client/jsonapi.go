@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// document is a JSON:API top-level document. T is either a single resource object or a slice of them.
+type document[T any] struct {
+	Data  T              `json:"data"`
+	Links pageLinks      `json:"links,omitempty"`
+	Meta  map[string]any `json:"meta,omitempty"`
+}
+
+// requestDocument is a JSON:API request body carrying a single resource object with attributes of type A.
+type requestDocument[A any] struct {
+	Data requestObject[A] `json:"data"`
+}
+
+type requestObject[A any] struct {
+	Id            string                  `json:"id,omitempty"`
+	Type          string                  `json:"type,omitempty"`
+	Attributes    A                       `json:"attributes"`
+	Relationships map[string]relationship `json:"relationships,omitempty"`
+}
+
+type relationship struct {
+	Data Data `json:"data"`
+}
+
+func newRequestDocument[A any](resourceType string, attributes A) requestDocument[A] {
+	return requestDocument[A]{
+		Data: requestObject[A]{
+			Type:       resourceType,
+			Attributes: attributes,
+		},
+	}
+}
+
+// queryOption adds JSON:API query parameters to a request.
+type queryOption func(query url.Values)
+
+// withFilter narrows a collection with a `filter[name]` query parameter.
+func withFilter(name, value string) queryOption {
+	return func(query url.Values) {
+		query.Set(fmt.Sprintf("filter[%s]", name), value)
+	}
+}
+
+// withSort orders a collection with a `sort` query parameter: ascending by field, or descending when it is prefixed
+// with `-`.
+func withSort(field string) queryOption {
+	return func(query url.Values) {
+		query.Set("sort", field)
+	}
+}
+
+// endpoint builds an Accounts API URL from path segments. Segments are escaped individually so identifiers
+// coming from configuration or upstream data can never change the shape of the path.
+func (v *VGSClient) endpoint(segments []string, options ...queryOption) (*url.URL, error) {
+	return joinEndpoint(v.serviceEndpoint, segments, options...)
+}
+
+// joinEndpoint appends escaped path segments and query parameters to a validated base URL.
+func joinEndpoint(base string, segments []string, options ...queryOption) (*url.URL, error) {
+	uri, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	escaped := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid path segment %q", segment)
+		}
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+
+	uri = uri.JoinPath(escaped...)
+	query := uri.Query()
+	for _, option := range options {
+		option(query)
+	}
+	uri.RawQuery = query.Encode()
+
+	return uri, nil
+}
+
+// getJSONAPI fetches a single JSON:API document.
+func getJSONAPI[T any](ctx context.Context, v *VGSClient, segments []string, options ...queryOption) (*document[T], *v2.RateLimitDescription, error) {
+	return getJSONAPIAt[T](ctx, v, v.serviceEndpoint, segments, options...)
+}
+
+// getJSONAPIAt fetches a single JSON:API document from an API other than the Accounts API, such as the vault
+// management API a vault advertises.
+func getJSONAPIAt[T any](ctx context.Context, v *VGSClient, base string, segments []string, options ...queryOption) (*document[T], *v2.RateLimitDescription, error) {
+	var doc document[T]
+	uri, err := joinEndpoint(base, segments, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, rateLimit, err := v.doRequest(ctx, http.MethodGet, uri, &doc)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	defer resp.Body.Close()
+	return &doc, rateLimit, nil
+}
+
+// listJSONAPI fetches one page of a JSON:API collection and returns its items along with the cursor of the next page.
+func listJSONAPI[T any](ctx context.Context, v *VGSClient, cursor string, segments []string, options ...queryOption) ([]T, string, *v2.RateLimitDescription, error) {
+	return listJSONAPIAt[T](ctx, v, v.serviceEndpoint, cursor, segments, options...)
+}
+
+// listJSONAPIAt fetches one page of a JSON:API collection from an API other than the Accounts API.
+func listJSONAPIAt[T any](ctx context.Context, v *VGSClient, base, cursor string, segments []string, options ...queryOption) ([]T, string, *v2.RateLimitDescription, error) {
+	doc, rateLimit, err := getJSONAPIAt[[]T](ctx, v, base, segments, append(options, withCursor(cursor))...)
+	if err != nil {
+		return nil, "", rateLimit, err
+	}
+
+	next, err := nextCursor(doc.Links)
+	if err != nil {
+		return nil, "", rateLimit, err
+	}
+
+	return doc.Data, next, rateLimit, nil
+}
+
+// sendJSONAPI sends a JSON:API request document and decodes the response document into response, unless it is nil.
+func sendJSONAPI[A any](ctx context.Context, v *VGSClient, method string, segments []string, body requestDocument[A], response any) (*v2.RateLimitDescription, error) {
+	return sendJSONAPIAt(ctx, v, v.serviceEndpoint, method, segments, body, response)
+}
+
+// sendJSONAPIAt sends a JSON:API request document to an API other than the Accounts API.
+func sendJSONAPIAt[A any](ctx context.Context, v *VGSClient, base, method string, segments []string, body requestDocument[A], response any) (*v2.RateLimitDescription, error) {
+	uri, err := joinEndpoint(base, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, rateLimit, err := v.doRequest(ctx, method, uri, response, WithJSONBodyV2(body))
+	if err != nil {
+		return rateLimit, err
+	}
+
+	defer resp.Body.Close()
+	return rateLimit, nil
+}
+
+// postJSONAPI creates a resource and returns the document the API answers with.
+func postJSONAPI[A, T any](ctx context.Context, v *VGSClient, segments []string, body requestDocument[A]) (*document[T], *v2.RateLimitDescription, error) {
+	return postJSONAPIAt[A, T](ctx, v, v.serviceEndpoint, segments, body)
+}
+
+// postJSONAPIAt creates a resource in an API other than the Accounts API.
+func postJSONAPIAt[A, T any](ctx context.Context, v *VGSClient, base string, segments []string, body requestDocument[A]) (*document[T], *v2.RateLimitDescription, error) {
+	var doc document[T]
+	rateLimit, err := sendJSONAPIAt(ctx, v, base, http.MethodPost, segments, body, &doc)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	return &doc, rateLimit, nil
+}
+
+// putJSONAPI updates a resource. The response body, if any, is ignored.
+func putJSONAPI[A any](ctx context.Context, v *VGSClient, segments []string, body requestDocument[A]) (*v2.RateLimitDescription, error) {
+	return sendJSONAPI(ctx, v, http.MethodPut, segments, body, nil)
+}
+
+// deleteJSONAPI deletes a resource.
+func deleteJSONAPI(ctx context.Context, v *VGSClient, segments []string) (*v2.RateLimitDescription, error) {
+	return deleteJSONAPIAt(ctx, v, v.serviceEndpoint, segments)
+}
+
+// deleteJSONAPIAt deletes a resource in an API other than the Accounts API.
+func deleteJSONAPIAt(ctx context.Context, v *VGSClient, base string, segments []string) (*v2.RateLimitDescription, error) {
+	uri, err := joinEndpoint(base, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, rateLimit, err := v.doRequest(ctx, http.MethodDelete, uri, nil, WithContentTypeVndHeader())
+	if err != nil {
+		return rateLimit, err
+	}
+
+	defer resp.Body.Close()
+	return rateLimit, nil
+}
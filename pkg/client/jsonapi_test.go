@@ -0,0 +1,92 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoint(t *testing.T) {
+	cli := &VGSClient{serviceEndpoint: "https://accounts.example.com/api"}
+
+	uri, err := cli.endpoint([]string{"vaults", "tnt1", "members"}, withCursor(""), withSort("occurred_at"))
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/vaults/tnt1/members", uri.Path)
+	assert.Equal(t, "", uri.Query().Get(pageNumberParam))
+	assert.Equal(t, "100", uri.Query().Get(pageSizeParam))
+	assert.Equal(t, "occurred_at", uri.Query().Get("sort"))
+
+	uri, err = cli.endpoint([]string{"vaults"}, withCursor("3"), withFilter("since", "2024-01-02T03:04:05Z"))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", uri.Query().Get(pageNumberParam))
+	assert.Equal(t, "2024-01-02T03:04:05Z", uri.Query().Get("filter[since]"))
+
+	uri, err = cli.endpoint([]string{"vaults", "a/b?c", "members"})
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/vaults/a%2Fb%3Fc/members", uri.EscapedPath())
+
+	_, err = cli.endpoint([]string{"vaults", "..", "members"})
+	assert.NotNil(t, err)
+	_, err = cli.endpoint([]string{"vaults", ""})
+	assert.NotNil(t, err)
+}
+
+func TestRequestDocumentEncoding(t *testing.T) {
+	body, err := json.Marshal(newRequestDocument("", vaultMemberAttributes{Role: `write","admin":"true`}))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"data":{"attributes":{"role":"write\",\"admin\":\"true"}}}`, string(body))
+}
+
+func TestJSONAPIVerbs(t *testing.T) {
+	tokenSrv, _ := newTokenServerForTesting(t, 300)
+	var bodies []string
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+r.URL.Path+" "+string(body))
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			_, _ = io.WriteString(w, `{"data":[{"id":"u1","attributes":{"role":"write"}}],"links":{"next":"/x?page[number]=2"}}`)
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"data":{"id":"u2","attributes":{"role":"admin"}}}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	cli := &VGSClient{
+		httpClient:      newBaseHttpClientForTesting(t, apiSrv),
+		tokens:          newTokenManagerForTesting(t, tokenSrv),
+		serviceEndpoint: apiSrv.URL,
+	}
+	segments := []string{"vaults", "tnt1", "members"}
+
+	users, next, _, err := listJSONAPI[vaultUserAPI](ctx, cli, "", segments)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", next)
+	assert.Equal(t, "write", users[0].Attributes.Role)
+
+	created, _, err := postJSONAPI[vaultMemberAttributes, vaultUserAPI](ctx, cli, segments, newRequestDocument("", vaultMemberAttributes{Role: "admin"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "u2", created.Data.Id)
+
+	_, err = putJSONAPI(ctx, cli, append(segments, "u2"), newRequestDocument("", vaultMemberAttributes{Role: "write"}))
+	assert.Nil(t, err)
+
+	_, err = deleteJSONAPI(ctx, cli, append(segments, "u2"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"GET /vaults/tnt1/members ",
+		"POST /vaults/tnt1/members {\"data\":{\"attributes\":{\"role\":\"admin\"}}}\n",
+		"PUT /vaults/tnt1/members/u2 {\"data\":{\"attributes\":{\"role\":\"write\"}}}\n",
+		"DELETE /vaults/tnt1/members/u2 ",
+	}, bodies)
+}
@@ -42,36 +42,78 @@ type OrganizationUser struct {
 	Name      string `json:"name,omitempty"`
 	Type      string `json:"type,omitempty"`
 	Email     string `json:"email,omitempty"`
+	Role      string `json:"role,omitempty"`
 	CreatedAt string `json:"created_at,omitempty"`
 	UpdatedAt string `json:"updated_at,omitempty"`
 }
 
-type Vault struct {
-	Id          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Environment string `json:"env_identifier,omitempty"`
-	CreatedAt   string `json:"created_at,omitempty"`
-	UpdatedAt   string `json:"updated_at,omitempty"`
+// Invite is an invitation to join an organization. Vaults lists the vault roles granted once it is accepted.
+type Invite struct {
+	Id        string        `json:"id,omitempty"`
+	Email     string        `json:"email,omitempty"`
+	InvitedBy string        `json:"invited_by,omitempty"`
+	Status    string        `json:"status,omitempty"`
+	Role      string        `json:"role,omitempty"`
+	Vaults    []InviteVault `json:"vaults,omitempty"`
+	CreatedAt string        `json:"created_at,omitempty"`
+}
+
+type InviteVault struct {
+	Id   string `json:"id,omitempty"`
+	Role string `json:"role,omitempty"`
 }
 
-type organizationsAPIData struct {
-	Data []organizationAPI `json:"data,omitempty"`
+// ServiceAccount is a client credentials identity of an organization. Scopes apply to the listed vaults.
+type ServiceAccount struct {
+	ClientId  string   `json:"client_id,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Vaults    []string `json:"vaults,omitempty"`
+	CreatedBy string   `json:"created_by,omitempty"`
+	CreatedAt string   `json:"created_at,omitempty"`
 }
 
-type organizationUsersAPIData struct {
-	Data []organizationUserAPI `json:"data,omitempty"`
+// ServiceAccountSecret is a client secret issued for a service account. The secret is only returned when issued.
+type ServiceAccountSecret struct {
+	ClientId       string `json:"client_id,omitempty"`
+	ClientSecret   string `json:"client_secret,omitempty"`
+	RevokePrevious bool   `json:"revoke_previous,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
 }
 
-type organizationInvitesAPIData struct {
-	Data []organizationInviteAPI `json:"data,omitempty"`
+// AuditLogEntry is an action recorded in an organization's audit log. The user, vault and role describe the subject
+// of the action, when it has one.
+type AuditLogEntry struct {
+	Id         string `json:"id,omitempty"`
+	Action     string `json:"action,omitempty"`
+	OccurredAt string `json:"occurred_at,omitempty"`
+	ActorId    string `json:"actor_id,omitempty"`
+	ActorEmail string `json:"actor_email,omitempty"`
+	UserId     string `json:"user_id,omitempty"`
+	UserEmail  string `json:"user_email,omitempty"`
+	VaultId    string `json:"vault_id,omitempty"`
+	Role       string `json:"role,omitempty"`
+	InviteId   string `json:"invite_id,omitempty"`
 }
 
-type organizationVaultsAPIData struct {
-	Data []organizationVaultAPI `json:"data,omitempty"`
+type Vault struct {
+	Id               string `json:"id,omitempty"`
+	Name             string `json:"name,omitempty"`
+	Environment      string `json:"env_identifier,omitempty"`
+	OrganizationId   string `json:"organization_id,omitempty"`
+	ManagementAPIURL string `json:"vault_management_api,omitempty"`
+	CreatedAt        string `json:"created_at,omitempty"`
+	UpdatedAt        string `json:"updated_at,omitempty"`
 }
 
-type vaultUsersAPIData struct {
-	Data []vaultUserAPI `json:"data,omitempty"`
+// VaultCredential is an access credential for the HTTP API of a vault. The secret is only returned when the
+// credential is issued.
+type VaultCredential struct {
+	Id        string `json:"id,omitempty"`
+	VaultId   string `json:"vault_id,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
 }
 
 type organizationVaultAPI struct {
@@ -104,6 +146,14 @@ type Data struct {
 	Type string `json:"type,omitempty"`
 }
 
+type pageLinks struct {
+	Self  string `json:"self,omitempty"`
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
 type Links struct {
 	Self    string `json:"self,omitempty"`
 	Related string `json:"related,omitempty"`
@@ -115,6 +165,30 @@ type organizationInviteAPI struct {
 	Attributes organizationInviteAPIAttributes `json:"attributes,omitempty"`
 }
 
+type serviceAccountAPI struct {
+	Id         string                      `json:"id,omitempty"`
+	Type       string                      `json:"type,omitempty"`
+	Attributes serviceAccountAPIAttributes `json:"attributes,omitempty"`
+}
+
+type serviceAccountSecretAPI struct {
+	Id         string                            `json:"id,omitempty"`
+	Type       string                            `json:"type,omitempty"`
+	Attributes serviceAccountSecretAPIAttributes `json:"attributes,omitempty"`
+}
+
+type vaultCredentialAPI struct {
+	Id         string                       `json:"id,omitempty"`
+	Type       string                       `json:"type,omitempty"`
+	Attributes vaultCredentialAPIAttributes `json:"attributes,omitempty"`
+}
+
+type auditLogAPI struct {
+	Id         string        `json:"id,omitempty"`
+	Type       string        `json:"type,omitempty"`
+	Attributes AuditLogEntry `json:"attributes,omitempty"`
+}
+
 type organizationAPI struct {
 	Id         string                    `json:"id,omitempty"`
 	Type       string                    `json:"type,omitempty"`
@@ -182,6 +256,39 @@ type organizationInviteAPIAttributes struct {
 	Vaults       []vaultAPIAttributes `json:"vaults,omitempty"`
 }
 
+type serviceAccountAPIAttributes struct {
+	ClientId  string                   `json:"client_id,omitempty"`
+	Name      string                   `json:"name,omitempty"`
+	Scopes    []serviceAccountAPIScope `json:"scopes,omitempty"`
+	Vaults    []string                 `json:"vaults,omitempty"`
+	CreatedBy string                   `json:"created_by,omitempty"`
+	CreatedAt string                   `json:"created_at,omitempty"`
+}
+
+type serviceAccountSecretAPIAttributes struct {
+	ClientId       string `json:"client_id,omitempty"`
+	ClientSecret   string `json:"client_secret,omitempty"`
+	RevokePrevious bool   `json:"revoke_previous,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+}
+
+type serviceAccountSecretRequestAttributes struct {
+	RevokePrevious bool `json:"revoke_previous"`
+}
+
+type vaultCredentialAPIAttributes struct {
+	Key       string `json:"key,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type vaultCredentialRequestAttributes struct{}
+
+type serviceAccountAPIScope struct {
+	Name string `json:"name,omitempty"`
+}
+
 type vaultAPIAttributes struct {
 	Id          string   `json:"id,omitempty"`
 	Name        string   `json:"name,omitempty"`
@@ -194,14 +301,140 @@ type vaultAPIAttributes struct {
 	UpdatedAt   string   `json:"updated_at,omitempty"`
 }
 
-type BodyAttributes struct {
-	Role string `json:"role,omitempty"`
+type vaultMemberAttributes struct {
+	Role string `json:"role"`
+}
+
+type vaultMemberCreateAttributes struct {
+	UserId string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type organizationInviteRequestAttributes struct {
+	UserEmail string                  `json:"user_email"`
+	Role      string                  `json:"role"`
+	Vaults    []inviteVaultAttributes `json:"vaults,omitempty"`
 }
 
-type BodyData struct {
-	Attributes BodyAttributes `json:"attributes,omitempty"`
+type inviteVaultAttributes struct {
+	Identifier string `json:"identifier"`
+	Role       string `json:"role"`
 }
 
-type Body struct {
-	Data BodyData `json:"data,omitempty"`
+type organizationMemberAttributes struct {
+	Role string `json:"role"`
+}
+
+func (o organizationAPI) toOrganization() Organization {
+	return Organization{
+		Id:        o.Id,
+		Name:      o.Attributes.Name,
+		State:     o.Attributes.State,
+		CreatedAt: o.Attributes.CreatedAt,
+		UpdatedAt: o.Attributes.UpdatedAt,
+	}
+}
+
+func (u organizationUserAPI) toOrganizationUser() OrganizationUser {
+	return OrganizationUser{
+		Id:        u.Id,
+		Name:      u.Attributes.Name,
+		Email:     u.Attributes.EmailAddress,
+		Role:      u.Attributes.Role,
+		CreatedAt: u.Attributes.CreatedAt,
+		UpdatedAt: u.Attributes.UpdatedAt,
+	}
+}
+
+func (i organizationInviteAPI) toInvite() Invite {
+	vaults := make([]InviteVault, 0, len(i.Attributes.Vaults))
+	for _, v := range i.Attributes.Vaults {
+		vaults = append(vaults, InviteVault{Id: v.Identifier, Role: v.Role})
+	}
+
+	id := i.Attributes.InviteId
+	if id == "" {
+		id = i.Id
+	}
+
+	return Invite{
+		Id:        id,
+		Email:     i.Attributes.UserEmail,
+		InvitedBy: i.Attributes.InvitedBy,
+		Status:    i.Attributes.InviteStatus,
+		Role:      i.Attributes.Role,
+		Vaults:    vaults,
+		CreatedAt: i.Attributes.CreatedAt,
+	}
+}
+
+func (s serviceAccountAPI) toServiceAccount() ServiceAccount {
+	scopes := make([]string, 0, len(s.Attributes.Scopes))
+	for _, scope := range s.Attributes.Scopes {
+		scopes = append(scopes, scope.Name)
+	}
+
+	clientId := s.Attributes.ClientId
+	if clientId == "" {
+		clientId = s.Id
+	}
+
+	return ServiceAccount{
+		ClientId:  clientId,
+		Name:      s.Attributes.Name,
+		Scopes:    scopes,
+		Vaults:    s.Attributes.Vaults,
+		CreatedBy: s.Attributes.CreatedBy,
+		CreatedAt: s.Attributes.CreatedAt,
+	}
+}
+
+func (s serviceAccountSecretAPI) toServiceAccountSecret() ServiceAccountSecret {
+	clientId := s.Attributes.ClientId
+	if clientId == "" {
+		clientId = s.Id
+	}
+
+	return ServiceAccountSecret{
+		ClientId:       clientId,
+		ClientSecret:   s.Attributes.ClientSecret,
+		RevokePrevious: s.Attributes.RevokePrevious,
+		CreatedAt:      s.Attributes.CreatedAt,
+	}
+}
+
+func (a auditLogAPI) toAuditLogEntry() AuditLogEntry {
+	entry := a.Attributes
+	if entry.Id == "" {
+		entry.Id = a.Id
+	}
+
+	return entry
+}
+
+func (o organizationVaultAPI) toVault() Vault {
+	return Vault{
+		Id:               o.Attributes.Identifier,
+		Name:             o.Attributes.Name,
+		Environment:      o.Attributes.Environment,
+		OrganizationId:   o.Relationships.Organization.Data.Id,
+		ManagementAPIURL: o.Links.VaultManagementApi,
+		CreatedAt:        o.Attributes.CreatedAt,
+		UpdatedAt:        o.Attributes.UpdatedAt,
+	}
+}
+
+func (c vaultCredentialAPI) toVaultCredential(vaultId string) VaultCredential {
+	id := c.Attributes.Key
+	if id == "" {
+		id = c.Id
+	}
+
+	return VaultCredential{
+		Id:        id,
+		VaultId:   vaultId,
+		Secret:    c.Attributes.Secret,
+		CreatedBy: c.Attributes.CreatedBy,
+		CreatedAt: c.Attributes.CreatedAt,
+	}
 }
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	pageSize        = 100
+	pageNumberParam = "page[number]"
+	pageSizeParam   = "page[size]"
+)
+
+// withCursor requests the page identified by cursor. An empty cursor requests the first page.
+func withCursor(cursor string) queryOption {
+	return func(query url.Values) {
+		query.Set(pageSizeParam, strconv.Itoa(pageSize))
+		if cursor != "" {
+			query.Set(pageNumberParam, cursor)
+		}
+	}
+}
+
+// nextCursor returns the cursor for the page after the current one, or an empty string on the last page.
+// The cursor is the page number taken from the `links.next` URL of the response, so no host or path from the
+// response is ever followed. A `links.next` without a page number is an error rather than the last page, so a
+// collection is never cut short without notice.
+func nextCursor(links pageLinks) (string, error) {
+	if links.Next == "" {
+		return "", nil
+	}
+
+	next, err := url.Parse(links.Next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link %q: %w", links.Next, err)
+	}
+
+	number := next.Query().Get(pageNumberParam)
+	if _, err := strconv.Atoi(number); err != nil {
+		return "", fmt.Errorf("next page link %q has no %s", links.Next, pageNumberParam)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextCursor(t *testing.T) {
+	cursor, err := nextCursor(pageLinks{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", cursor)
+
+	cursor, err = nextCursor(pageLinks{Next: "https://accounts.example.com/vaults?page%5Bnumber%5D=2&page%5Bsize%5D=100"})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", cursor)
+
+	cursor, err = nextCursor(pageLinks{Next: "/organizations/AC1/members?page[number]=5"})
+	assert.Nil(t, err)
+	assert.Equal(t, "5", cursor)
+
+	// A next link the cursor cannot be taken from fails instead of ending the collection.
+	_, err = nextCursor(pageLinks{Next: "https://evil.example.com/?page[number]=../../x"})
+	assert.ErrorContains(t, err, "page[number]")
+	_, err = nextCursor(pageLinks{Next: "/organizations/AC1/members?page[cursor]=abc"})
+	assert.NotNil(t, err)
+	_, err = nextCursor(pageLinks{Next: "%zz"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultRetryAfter is how long to back off when the API answers 429 without saying for how long.
+const defaultRetryAfter = 60 * time.Second
+
+// withRateLimitData fills rateLimit from the rate limit headers of the response. Unlike uhttp.WithRatelimitData
+// it never fails the request over a malformed header, understands Retry-After as an HTTP date, and makes sure a
+// 429 always carries a reset time the SDK can wait for.
+func withRateLimitData(rateLimit *v2.RateLimitDescription) uhttp.DoOption {
+	return func(resp *uhttp.WrapperResponse) error {
+		// The SDK parser rejects HTTP dates, which are valid Retry-After values; they are handled below.
+		_ = uhttp.WithRatelimitData(rateLimit)(resp)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return nil
+		}
+
+		rateLimit.Status = v2.RateLimitDescription_STATUS_OVERLIMIT
+		rateLimit.Remaining = 0
+		if resetAt, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			rateLimit.ResetAt = timestamppb.New(resetAt)
+		} else if rateLimit.ResetAt == nil || !rateLimit.ResetAt.AsTime().After(time.Now()) {
+			rateLimit.ResetAt = timestamppb.New(time.Now().Add(defaultRetryAfter))
+		}
+
+		// The SDK spreads the wait until ResetAt over Limit requests. Without a limit header it would
+		// ignore the reset time altogether, so wait for all of it.
+		if rateLimit.Limit <= 0 {
+			rateLimit.Limit = 1
+		}
+
+		return nil
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return at, true
+}
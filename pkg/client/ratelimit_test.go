@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	at, ok := parseRetryAfter("30", now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(30*time.Second), at)
+
+	at, ok = parseRetryAfter("Wed, 01 May 2024 12:02:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(2*time.Minute), at)
+
+	for _, value := range []string{"", "-5", "soon"} {
+		_, ok = parseRetryAfter(value, now)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestDoRequestReportsRateLimit(t *testing.T) {
+	tokenSrv, _ := newTokenServerForTesting(t, 300)
+	limited := true
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		if limited {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"errors":[{"status":"429","title":"Too Many Requests"}]}`)
+			return
+		}
+
+		w.Header().Set("X-Ratelimit-Limit", "100")
+		w.Header().Set("X-Ratelimit-Remaining", "42")
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	cli := &VGSClient{
+		httpClient:      newBaseHttpClientForTesting(t, apiSrv),
+		tokens:          newTokenManagerForTesting(t, tokenSrv),
+		serviceEndpoint: apiSrv.URL,
+	}
+
+	_, _, rateLimit, err := cli.ListVaults(ctx, "")
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, rateLimit, apiErr.RateLimit)
+	assert.Equal(t, v2.RateLimitDescription_STATUS_OVERLIMIT, rateLimit.Status)
+	assert.Equal(t, int64(1), rateLimit.Limit)
+	assert.WithinDuration(t, time.Now().Add(120*time.Second), rateLimit.ResetAt.AsTime(), 5*time.Second)
+
+	limited = false
+	_, _, rateLimit, err = cli.ListVaults(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, v2.RateLimitDescription_STATUS_OK, rateLimit.Status)
+	assert.Equal(t, int64(100), rateLimit.Limit)
+	assert.Equal(t, int64(42), rateLimit.Remaining)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// retryPolicy controls how requests that failed for transient reasons are retried.
+// The zero value disables retries.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 4,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    10 * time.Second,
+}
+
+// retryableStatusCodes are the gateway errors the Accounts API answers with while it is briefly unavailable.
+// 429 is left to the SDK, which waits for the rate limit to reset.
+var retryableStatusCodes = map[int]struct{}{
+	http.StatusBadGateway:         {},
+	http.StatusServiceUnavailable: {},
+	http.StatusGatewayTimeout:     {},
+}
+
+// idempotentMethod reports whether repeating a request with the given method cannot change its outcome.
+// POST is never retried since it could create the same object twice.
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// shouldRetry reports whether a request that ended with resp and err is worth sending again.
+func (p retryPolicy) shouldRetry(ctx context.Context, method string, resp *http.Response, err error) bool {
+	if !idempotentMethod(method) || ctx.Err() != nil {
+		return false
+	}
+
+	if resp == nil {
+		// No response at all: connection refused or reset, DNS failure, timeout.
+		return err != nil && !errors.Is(err, context.Canceled)
+	}
+
+	_, ok := retryableStatusCodes[resp.StatusCode]
+	return ok
+}
+
+// backoff returns how long to wait before the given retry, counting from 1. The delay grows exponentially
+// up to maxDelay and is jittered so concurrent syncs do not retry in lockstep.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < retry && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec // jitter does not need a CSPRNG
+}
+
+// wait sleeps for d or until ctx is done, whichever comes first.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
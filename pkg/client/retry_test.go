@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := retryPolicy{maxAttempts: 5, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+	for retry, ceiling := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(retry)
+			assert.GreaterOrEqual(t, d, ceiling/2)
+			assert.LessOrEqual(t, d, ceiling)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), retryPolicy{}.backoff(1))
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := defaultRetryPolicy
+	networkErr := errors.New("connection reset by peer")
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable}
+
+	assert.True(t, p.shouldRetry(ctx, http.MethodGet, nil, networkErr))
+	assert.True(t, p.shouldRetry(ctx, http.MethodPut, unavailable, nil))
+	assert.True(t, p.shouldRetry(ctx, http.MethodDelete, &http.Response{StatusCode: http.StatusBadGateway}, nil))
+	assert.False(t, p.shouldRetry(ctx, http.MethodPost, unavailable, nil))
+	assert.False(t, p.shouldRetry(ctx, http.MethodGet, &http.Response{StatusCode: http.StatusInternalServerError}, nil))
+	assert.False(t, p.shouldRetry(ctx, http.MethodGet, &http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, p.shouldRetry(canceled, http.MethodGet, nil, networkErr))
+}
+
+func newRetryingClientForTesting(t *testing.T, failures int32, status int) (*VGSClient, *int32) {
+	tokenSrv, _ := newTokenServerForTesting(t, 300)
+	var calls int32
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	return &VGSClient{
+		httpClient:      newBaseHttpClientForTesting(t, apiSrv),
+		tokens:          newTokenManagerForTesting(t, tokenSrv),
+		retry:           retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond},
+		serviceEndpoint: apiSrv.URL,
+	}, &calls
+}
+
+func TestDoRequestRetriesGatewayErrors(t *testing.T) {
+	cli, calls := newRetryingClientForTesting(t, 2, http.StatusBadGateway)
+	_, _, _, err := cli.ListVaults(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	cli, calls = newRetryingClientForTesting(t, 5, http.StatusServiceUnavailable)
+	_, err = deleteJSONAPI(ctx, cli, []string{"vaults", "tnt1", "members", "u1"})
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestDoRequestDoesNotRetryPost(t *testing.T) {
+	cli, calls := newRetryingClientForTesting(t, 1, http.StatusServiceUnavailable)
+	_, _, err := postJSONAPI[vaultMemberAttributes, vaultUserAPI](ctx, cli, []string{"vaults", "tnt1", "members"}, newRequestDocument("", vaultMemberAttributes{Role: "write"}))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestDoRequestStopsRetryingWhenContextIsDone(t *testing.T) {
+	cli, calls := newRetryingClientForTesting(t, 5, http.StatusGatewayTimeout)
+	cli.retry = retryPolicy{maxAttempts: 5, baseDelay: time.Minute, maxDelay: time.Minute}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, _, _, err := cli.ListVaults(timeoutCtx, "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
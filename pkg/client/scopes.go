@@ -0,0 +1,62 @@
+package client
+
+import (
+	"sort"
+	"strings"
+)
+
+// Scopes granted to VGS service accounts that the connector relies on.
+const (
+	ScopeOrganizationUsersRead  = "organization-users:read"
+	ScopeOrganizationUsersWrite = "organization-users:write"
+	ScopeOrganizationsRead      = "organizations:read"
+	ScopeVaultsRead             = "vaults:read"
+	ScopeVaultsWrite            = "vaults:write"
+	ScopeServiceAccountsRead    = "service-accounts:read"
+	ScopeServiceAccountsWrite   = "service-accounts:write"
+	ScopeCredentialsRead        = "credentials:read"
+	ScopeCredentialsWrite       = "credentials:write"
+	ScopeAuditLogsRead          = "audit-logs:read"
+)
+
+// Scopes is the set of scopes carried by an access token.
+type Scopes map[string]struct{}
+
+// ParseScopes parses the space separated scope claim of an access token.
+func ParseScopes(scope string) Scopes {
+	scopes := Scopes{}
+	for _, s := range strings.Fields(scope) {
+		scopes[s] = struct{}{}
+	}
+
+	return scopes
+}
+
+// Has reports whether the given scope was granted.
+func (s Scopes) Has(scope string) bool {
+	_, ok := s[scope]
+	return ok
+}
+
+// Missing returns the scopes from the given list that were not granted.
+func (s Scopes) Missing(scopes ...string) []string {
+	var missing []string
+	for _, scope := range scopes {
+		if !s.Has(scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// List returns the granted scopes in sorted order.
+func (s Scopes) List() []string {
+	list := make([]string, 0, len(s))
+	for scope := range s {
+		list = append(list, scope)
+	}
+	sort.Strings(list)
+
+	return list
+}
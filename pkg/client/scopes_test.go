@@ -0,0 +1,15 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes := ParseScopes("openid organization-users:read  organization-users:write-all")
+	assert.True(t, scopes.Has(ScopeOrganizationUsersRead))
+	assert.False(t, scopes.Has(ScopeOrganizationUsersWrite))
+	assert.Equal(t, []string{ScopeOrganizationUsersWrite}, scopes.Missing(ScopeOrganizationUsersRead, ScopeOrganizationUsersWrite))
+	assert.Equal(t, []string{"openid", "organization-users:read", "organization-users:write-all"}, scopes.List())
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// tokenRefreshWindow is how long before expiry a token is considered stale and gets refreshed.
+const tokenRefreshWindow = 60 * time.Second
+
+// tokenManager hands out client-credentials access tokens, fetching a new one ahead of expiry
+// or whenever the current one has been rejected. It is safe for concurrent use.
+type tokenManager struct {
+	mtx          sync.Mutex
+	httpClient   *uhttp.BaseHttpClient
+	tokenURL     *url.URL
+	clientId     string
+	clientSecret string
+	token        *JWT
+	refreshAt    time.Time
+	now          func() time.Time
+}
+
+func newTokenManager(httpClient *uhttp.BaseHttpClient, tokenURL *url.URL, clientId, clientSecret string) *tokenManager {
+	return &tokenManager{
+		httpClient:   httpClient,
+		tokenURL:     tokenURL,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		now:          time.Now,
+	}
+}
+
+// Token returns a valid access token, fetching a new one when none is cached or the cached one is about to expire.
+func (t *tokenManager) Token(ctx context.Context) (*JWT, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.token != nil && t.now().Before(t.refreshAt) {
+		return t.token, nil
+	}
+
+	jwt, err := t.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t.token = jwt
+	t.refreshAt = t.now().Add(refreshAfter(jwt.ExpiresIn))
+	ctxzap.Extract(ctx).Debug("baton-vgs: fetched access token",
+		zap.Int("expires_in", jwt.ExpiresIn),
+		zap.Time("refresh_at", t.refreshAt),
+	)
+
+	return t.token, nil
+}
+
+// Invalidate drops the cached token if it is still the given one, so the next call to Token fetches a new one.
+// Comparing against the rejected token keeps concurrent callers from discarding a token that was just refreshed.
+func (t *tokenManager) Invalidate(accessToken string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.token != nil && t.token.AccessToken == accessToken {
+		t.token = nil
+	}
+}
+
+func (t *tokenManager) fetch(ctx context.Context) (*JWT, error) {
+	var jwt JWT
+	req, err := t.httpClient.NewRequest(ctx,
+		http.MethodPost,
+		t.tokenURL,
+		uhttp.WithAcceptJSONHeader(),
+		WithBody(`grant_type=client_credentials`),
+		WithSetBasicAuthHeader(t.clientId, t.clientSecret),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.httpClient.Do(req, withOptionalJSONResponse(&jwt))
+	if resp != nil && resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if jwt.AccessToken == "" {
+		return nil, errors.New("token is not valid")
+	}
+
+	return &jwt, nil
+}
+
+// refreshAfter returns how long a token with the given lifetime can be used before it should be refreshed.
+// Short-lived tokens are refreshed halfway through their lifetime instead of a fixed window before expiry.
+func refreshAfter(expiresIn int) time.Duration {
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= 2*tokenRefreshWindow {
+		return lifetime / 2
+	}
+
+	return lifetime - tokenRefreshWindow
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTokenServerForTesting(t *testing.T, expiresIn int) (*httptest.Server, *int32) {
+	var issued int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "id" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d,"scope":"organization-users:read"}`, n, expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &issued
+}
+
+// newBaseHttpClientForTesting returns a client for srv with the shared uhttp response cache disabled,
+// since its keys do not include the host and would leak responses between test servers.
+func newBaseHttpClientForTesting(t *testing.T, srv *httptest.Server) *uhttp.BaseHttpClient {
+	cacheCtx := context.WithValue(ctx, uhttp.ContextKey{}, uhttp.CacheConfig{DisableCache: true})
+	cli, err := uhttp.NewBaseHttpClientWithContext(cacheCtx, srv.Client())
+	assert.Nil(t, err)
+
+	return cli
+}
+
+func newTokenManagerForTesting(t *testing.T, srv *httptest.Server) *tokenManager {
+	uri, err := url.Parse(srv.URL)
+	assert.Nil(t, err)
+
+	return newTokenManager(newBaseHttpClientForTesting(t, srv), uri, "id", "secret")
+}
+
+func TestRefreshAfter(t *testing.T) {
+	assert.Equal(t, 240*time.Second, refreshAfter(300))
+	assert.Equal(t, 30*time.Second, refreshAfter(60))
+	assert.Equal(t, time.Duration(0), refreshAfter(0))
+}
+
+func TestTokenManagerRefreshesBeforeExpiry(t *testing.T) {
+	srv, issued := newTokenServerForTesting(t, 300)
+	tm := newTokenManagerForTesting(t, srv)
+	now := time.Now()
+	tm.now = func() time.Time { return now }
+
+	jwt, err := tm.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-1", jwt.AccessToken)
+
+	now = now.Add(200 * time.Second)
+	jwt, err = tm.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-1", jwt.AccessToken)
+
+	now = now.Add(41 * time.Second)
+	jwt, err = tm.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-2", jwt.AccessToken)
+	assert.Equal(t, int32(2), atomic.LoadInt32(issued))
+}
+
+func TestTokenManagerInvalidate(t *testing.T) {
+	srv, _ := newTokenServerForTesting(t, 300)
+	tm := newTokenManagerForTesting(t, srv)
+
+	jwt, err := tm.Token(ctx)
+	assert.Nil(t, err)
+
+	// A stale token must not discard the current one.
+	tm.Invalidate("something-else")
+	current, err := tm.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, jwt.AccessToken, current.AccessToken)
+
+	tm.Invalidate(jwt.AccessToken)
+	current, err = tm.Token(ctx)
+	assert.Nil(t, err)
+	assert.NotEqual(t, jwt.AccessToken, current.AccessToken)
+}
+
+func TestTokenManagerConcurrentCallers(t *testing.T) {
+	srv, issued := newTokenServerForTesting(t, 300)
+	tm := newTokenManagerForTesting(t, srv)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tm.Token(ctx)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(issued))
+}
+
+func TestTokenManagerInvalidCredentials(t *testing.T) {
+	srv, _ := newTokenServerForTesting(t, 300)
+	tm := newTokenManagerForTesting(t, srv)
+	tm.clientSecret = "wrong"
+
+	_, err := tm.Token(ctx)
+	assert.NotNil(t, err)
+}
+
+func TestDoRequestRetriesWithFreshTokenOnUnauthorized(t *testing.T) {
+	tokenSrv, issued := newTokenServerForTesting(t, 300)
+	var calls int32
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	cli := &VGSClient{
+		httpClient:      newBaseHttpClientForTesting(t, apiSrv),
+		tokens:          newTokenManagerForTesting(t, tokenSrv),
+		serviceEndpoint: apiSrv.URL,
+	}
+	uri, err := url.Parse(apiSrv.URL + "/vaults")
+	assert.Nil(t, err)
+
+	var data document[[]organizationVaultAPI]
+	resp, _, err := cli.doRequest(ctx, http.MethodPost, uri, &data)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(issued))
+}
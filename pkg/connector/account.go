@@ -0,0 +1,178 @@
+package connector
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Account profile fields read by CreateAccount.
+const (
+	accountProfileRole         = "role"
+	accountProfileVaultRoles   = "vault_roles"
+	accountProfileOrganization = "organization_id"
+)
+
+// accountRequest is an invitation to send, built from the account info of a CreateAccount call.
+type accountRequest struct {
+	organizationId string
+	email          string
+	role           string
+	vaults         []client.InviteVault
+}
+
+// newAccountRequest reads the invitee email from the account info and the organization and roles from its
+// profile. The org role defaults to member. Vault roles are either an object of vault identifiers to roles or a
+// comma-separated list of vault:role pairs.
+func newAccountRequest(accountInfo *v2.AccountInfo) (*accountRequest, error) {
+	req := &accountRequest{
+		email: accountEmail(accountInfo),
+		role:  orgRoleMember,
+	}
+	if req.email == "" {
+		return nil, status.Error(codes.InvalidArgument, "baton-vgs: an email address is required to invite a user")
+	}
+
+	profile := accountInfo.GetProfile().AsMap()
+	if orgId, ok := profile[accountProfileOrganization].(string); ok {
+		req.organizationId = strings.TrimSpace(orgId)
+	}
+	if role, ok := profile[accountProfileRole].(string); ok && role != "" {
+		req.role = strings.ToLower(role)
+	}
+	if !slices.Contains(orgAccessLevels, req.role) {
+		return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: unknown organization role %q", req.role)
+	}
+
+	vaultRoles, err := parseVaultRoles(profile[accountProfileVaultRoles])
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(vaultRoles))
+	for id := range vaultRoles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		role := vaultRoles[id]
+		if !slices.Contains(vaultAccessLevels, role) {
+			return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: unknown role %q for vault %s", role, id)
+		}
+		req.vaults = append(req.vaults, client.InviteVault{Id: id, Role: role})
+	}
+
+	return req, nil
+}
+
+// accountEmail prefers the primary email, then any email, then a login that looks like one.
+func accountEmail(accountInfo *v2.AccountInfo) string {
+	emails := accountInfo.GetEmails()
+	for _, e := range emails {
+		if e.GetIsPrimary() && e.GetAddress() != "" {
+			return e.GetAddress()
+		}
+	}
+
+	for _, e := range emails {
+		if e.GetAddress() != "" {
+			return e.GetAddress()
+		}
+	}
+
+	if login := accountInfo.GetLogin(); strings.Contains(login, "@") {
+		return login
+	}
+
+	return ""
+}
+
+func parseVaultRoles(value interface{}) (map[string]string, error) {
+	roles := map[string]string{}
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for id, role := range v {
+			r, ok := role.(string)
+			if !ok {
+				return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: role for vault %s must be a string", id)
+			}
+			roles[id] = strings.ToLower(r)
+		}
+	case string:
+		for _, pair := range strings.Split(v, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			id, role, ok := strings.Cut(pair, ":")
+			if !ok || id == "" || role == "" {
+				return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: invalid vault role %q, expected vault:role", pair)
+			}
+			roles[strings.TrimSpace(id)] = strings.ToLower(strings.TrimSpace(role))
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: %s must be an object or a string", accountProfileVaultRoles)
+	}
+
+	return roles, nil
+}
+
+// offboardReport records what Delete removed for a user.
+type offboardReport struct {
+	organizationId                string
+	userId                        string
+	email                         string
+	vaultMemberships              []client.InviteVault
+	organizationMembershipRemoved bool
+	invitesCancelled              []string
+}
+
+func (r *offboardReport) vaultIds() []string {
+	ids := make([]string, 0, len(r.vaultMemberships))
+	for _, v := range r.vaultMemberships {
+		ids = append(ids, v.Id)
+	}
+
+	return ids
+}
+
+func (r *offboardReport) fields() []zap.Field {
+	return []zap.Field{
+		zap.String("organization_id", r.organizationId),
+		zap.String("user_id", r.userId),
+		zap.Strings("vault_memberships_removed", r.vaultIds()),
+		zap.Bool("organization_membership_removed", r.organizationMembershipRemoved),
+		zap.Strings("invites_cancelled", r.invitesCancelled),
+	}
+}
+
+// toStruct returns the report as a struct annotation.
+func (r *offboardReport) toStruct() (*structpb.Struct, error) {
+	vaults := make([]interface{}, 0, len(r.vaultMemberships))
+	for _, v := range r.vaultMemberships {
+		vaults = append(vaults, map[string]interface{}{"vault_id": v.Id, "role": v.Role})
+	}
+
+	invites := make([]interface{}, 0, len(r.invitesCancelled))
+	for _, id := range r.invitesCancelled {
+		invites = append(invites, id)
+	}
+
+	return structpb.NewStruct(map[string]interface{}{
+		"organization_id":                 r.organizationId,
+		"user_id":                         r.userId,
+		"email":                           r.email,
+		"vault_memberships_removed":       vaults,
+		"organization_membership_removed": r.organizationMembershipRemoved,
+		"invites_cancelled":               invites,
+	})
+}
@@ -2,27 +2,39 @@ package connector
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
 type (
 	Connector struct {
-		client *client.VGSClient
+		client                *client.VGSClient
+		state                 *syncState
+		inviteStatuses        []string
+		revokePreviousSecrets bool
+		allowSelfRotation     bool
+		revokeReplaced        bool
 	}
 )
 
 // ResourceSyncers returns a ResourceSyncer for each resource type that should be synced from the upstream service.
 func (d *Connector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
 	return []connectorbuilder.ResourceSyncer{
-		userBuilder(d.client),
-		orgBuilder(d.client),
+		userBuilder(d.client, d.state),
+		inviteBuilder(d.client, d.state, d.inviteStatuses),
+		orgBuilder(d.client, d.state),
+		serviceAccountBuilder(d.client, d.state, d.revokePreviousSecrets, d.allowSelfRotation),
 		vaultBuilder(d.client),
+		vaultCredentialBuilder(d.client, d.state, d.revokeReplaced),
 	}
 }
 
@@ -36,16 +48,108 @@ func (d *Connector) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.R
 func (d *Connector) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 	return &v2.ConnectorMetadata{
 		DisplayName: "VGS Connector",
-		Description: "Connector syncing users, organizations and vaults from VGS.",
+		Description: "Connector syncing users, invites, service accounts, organizations, vaults and vault credentials from VGS.",
 	}, nil
 }
 
+// capabilityScopes lists the service account scopes each connector capability depends on.
+var capabilityScopes = []struct {
+	capability string
+	scopes     []string
+}{
+	{capability: "sync", scopes: []string{client.ScopeOrganizationUsersRead}},
+	{capability: "sync service accounts", scopes: []string{client.ScopeServiceAccountsRead}},
+	{capability: "rotate service account secrets", scopes: []string{client.ScopeServiceAccountsWrite}},
+	{capability: "sync vault credentials", scopes: []string{client.ScopeCredentialsRead}},
+	{capability: "rotate and revoke vault credentials", scopes: []string{client.ScopeCredentialsWrite}},
+	{capability: "event feed", scopes: []string{client.ScopeAuditLogsRead}},
+	{capability: "create accounts", scopes: []string{client.ScopeOrganizationUsersRead, client.ScopeOrganizationUsersWrite}},
+	{capability: "delete users", scopes: []string{client.ScopeOrganizationUsersRead, client.ScopeOrganizationUsersWrite}},
+	{capability: "provision organization roles", scopes: []string{client.ScopeOrganizationUsersWrite}},
+	{capability: "provision vault roles", scopes: []string{client.ScopeOrganizationUsersWrite}},
+}
+
 // Validate is called to ensure that the connector is properly configured. It should exercise any API credentials
 // to be sure that they are valid.
 func (d *Connector) Validate(ctx context.Context) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if d.client == nil {
+		return nil, fmt.Errorf("baton-vgs: %s and %s are required", client.ServiceAccountClientIdName, client.ServiceAccountClientSecretName)
+	}
+
+	scopes, err := d.client.GetScopes(ctx)
+	if err != nil {
+		return nil, wrapError(err, "baton-vgs: failed to authenticate service account")
+	}
+
+	var usable, unusable []string
+	for _, cs := range capabilityScopes {
+		missing := scopes.Missing(cs.scopes...)
+		if len(missing) > 0 {
+			unusable = append(unusable, fmt.Sprintf("%s (missing %s)", cs.capability, strings.Join(missing, ", ")))
+			continue
+		}
+		usable = append(usable, cs.capability)
+	}
+
+	if missing := scopes.Missing(client.ScopeOrganizationUsersRead); len(missing) > 0 {
+		return nil, fmt.Errorf("baton-vgs: service account is missing required scopes: %s", strings.Join(missing, ", "))
+	}
+
+	// Baton validates the connector at the start of every sync, so what the previous sync worked out is dropped here.
+	// Every synced organization must be readable. Organizations discovered without configuration whose members the
+	// service account cannot read are already left out of the sync.
+	d.state.reset()
+	orgIds, _, err := d.state.organizationIds(ctx)
+	if err != nil {
+		return nil, wrapError(err, "baton-vgs: organizations could not be listed")
+	}
+	if len(orgIds) == 0 {
+		return nil, fmt.Errorf("baton-vgs: service account cannot read any organization")
+	}
+
+	for _, orgId := range orgIds {
+		err = d.validateOrganization(ctx, orgId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if vaultId := d.client.GetVaultId(); vaultId != "" {
+		_, _, err = d.client.GetVault(ctx, vaultId)
+		if err != nil {
+			return nil, wrapError(err, fmt.Sprintf("baton-vgs: vault %s could not be read", vaultId))
+		}
+	}
+
+	l.Info("baton-vgs: validated service account",
+		zap.Strings("organization_ids", orgIds),
+		zap.Strings("scopes", scopes.List()),
+		zap.Strings("usable_capabilities", usable),
+		zap.Strings("unusable_capabilities", unusable),
+	)
+	if len(unusable) > 0 {
+		l.Warn("baton-vgs: some capabilities are disabled by the service account scopes", zap.Strings("unusable_capabilities", unusable))
+	}
+
 	return nil, nil
 }
 
+// validateOrganization checks that the organization and its members can be read.
+func (d *Connector) validateOrganization(ctx context.Context, orgId string) error {
+	_, _, err := d.client.GetOrganization(ctx, orgId)
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("baton-vgs: organization %s could not be read", orgId))
+	}
+
+	_, _, _, err = d.client.ListUsers(ctx, orgId, "")
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("baton-vgs: members of organization %s could not be read", orgId))
+	}
+
+	return nil
+}
+
 // New returns a new instance of the connector.
 func New(ctx context.Context, cfg *viper.Viper) (*Connector, error) {
 	var (
@@ -53,13 +157,21 @@ func New(ctx context.Context, cfg *viper.Viper) (*Connector, error) {
 		config         = client.Config{}
 		clientId       = cfg.GetString(client.ServiceAccountClientIdName)
 		clientSecret   = cfg.GetString(client.ServiceAccountClientSecretName)
-		organizationId = cfg.GetString(client.OrganizationId)
+		orgIds         = cfg.GetStringSlice(client.OrganizationId)
 		vaultId        = cfg.GetString(client.VaultId)
+		authRealmURL   = cfg.GetString(client.AuthRealmURL)
+		accountsAPIURL = cfg.GetString(client.AccountsAPIURL)
+		allowInsecure  = cfg.GetBool(client.AllowInsecureEndpoints)
+		inviteStatuses = cfg.GetStringSlice(client.InviteStatuses)
+		revokeSecrets  = cfg.GetBool(client.RevokePreviousSecrets)
+		allowSelf      = cfg.GetBool(client.AllowSelfRotation)
+		revokeReplaced = cfg.GetBool(client.RevokeReplacedVaultCredentials)
 		err            error
 	)
 
 	config.WithServiceAccountClientId(clientId).WithServiceAccountClientSecret(clientSecret)
-	config.WithOrganizationId(organizationId).WithVaultId(vaultId)
+	config.WithOrganizationIds(orgIds...).WithVaultId(vaultId)
+	config.WithAuthRealmURL(authRealmURL).WithAccountsAPIURL(accountsAPIURL).WithAllowInsecureEndpoints(allowInsecure)
 	if clientId != "" && clientSecret != "" {
 		vc, err = client.New(ctx, config)
 		if err != nil {
@@ -68,6 +180,11 @@ func New(ctx context.Context, cfg *viper.Viper) (*Connector, error) {
 	}
 
 	return &Connector{
-		client: vc,
+		client:                vc,
+		state:                 newSyncState(vc),
+		inviteStatuses:        inviteStatuses,
+		revokePreviousSecrets: revokeSecrets,
+		allowSelfRotation:     allowSelf,
+		revokeReplaced:        revokeReplaced,
 	}, nil
 }
@@ -0,0 +1,1083 @@
+package connector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rsutil "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/conductorone/baton-vgs/pkg/vgsfake"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const fixtureForTesting = "../vgsfake/testdata/basic.json"
+
+// newFakeConnectorForTesting returns a connector configured against the fake as the given service account.
+func newFakeConnectorForTesting(t *testing.T, s *vgsfake.Server, id, secret string) *Connector {
+	return newFakeConnectorForOrganizations(t, s, id, secret, "ACorg1")
+}
+
+// newFakeConnectorForOrganizations returns a connector syncing the given organizations, or every organization the
+// service account can see when none are given.
+func newFakeConnectorForOrganizations(t *testing.T, s *vgsfake.Server, id, secret string, orgIds ...string) *Connector {
+	cfg := viper.New()
+	cfg.Set(client.ServiceAccountClientIdName, id)
+	cfg.Set(client.ServiceAccountClientSecretName, secret)
+	cfg.Set(client.OrganizationId, orgIds)
+	cfg.Set(client.AuthRealmURL, s.AuthRealmURL())
+	cfg.Set(client.AccountsAPIURL, s.AccountsAPIURL())
+	cfg.Set(client.AllowInsecureEndpoints, true)
+
+	c, err := New(ctx, cfg)
+	assert.Nil(t, err)
+
+	return c
+}
+
+func TestValidateAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	_, err := c.Validate(ctx)
+	assert.Nil(t, err)
+	s.AssertCalled(t, http.MethodGet, "/organizations/ACorg1")
+
+	s.Seed(vgsfake.Fixture{Clients: []vgsfake.Client{{Id: "ACccc-none", Secret: "none"}}})
+	c = newFakeConnectorForTesting(t, s, "ACccc-none", "none")
+	_, err = c.Validate(ctx)
+	assert.NotNil(t, err)
+}
+
+func TestSyncAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting, vgsfake.WithMaxPageSize(2))
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+
+	users := userBuilder(c.client, c.state)
+	var (
+		all   []*v2.Resource
+		token = &pagination.Token{}
+	)
+	for {
+		rs, next, _, err := users.List(ctx, org, token)
+		assert.Nil(t, err)
+		all = append(all, rs...)
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+	assert.Len(t, all, 3)
+	s.AssertCallCount(t, http.MethodGet, "/organizations/ACorg1/members", 2)
+
+	assert.Equal(t, org, all[0].ParentResourceId)
+
+	vaults := vaultBuilder(c.client)
+	rs, _, _, err := vaults.List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+	assert.Equal(t, org, rs[0].ParentResourceId)
+
+	// Users and vaults are only listed under their organization.
+	for _, parent := range []*v2.ResourceId{nil, {ResourceType: resourceTypeOrg.Id, Resource: "ACother"}} {
+		empty, _, _, err := vaults.List(ctx, parent, &pagination.Token{})
+		assert.Nil(t, err)
+		assert.Empty(t, empty)
+	}
+	empty, _, _, err := users.List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, empty)
+
+	grants, next, _, err := vaults.Grants(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, next)
+	assert.Len(t, grants, 2)
+	assert.Equal(t, "IDalice", grants[0].Principal.Id.Resource)
+}
+
+func TestInvitesAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+
+	rs, _, _, err := inviteBuilder(c.client, c.state, nil).List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+
+	rs, _, _, err = inviteBuilder(c.client, c.state, nil).List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	if !assert.Len(t, rs, 1) {
+		return
+	}
+	assert.Equal(t, "INVpending", rs[0].Id.Resource)
+	assert.Equal(t, org, rs[0].ParentResourceId)
+	assert.Equal(t, "dave@example.com", rs[0].DisplayName)
+
+	trait, err := rsutil.GetUserTrait(rs[0])
+	assert.Nil(t, err)
+	assert.Equal(t, v2.UserTrait_Status_STATUS_DISABLED, trait.Status.Status)
+	profile := trait.Profile.AsMap()
+	assert.Equal(t, "alice@example.com", profile["invited_by"])
+	assert.Equal(t, "MEMBER", profile["role"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "tntsandbox", "role": "write"}}, profile["vaults"])
+
+	rs, _, _, err = inviteBuilder(c.client, c.state, []string{"pending", "expired"}).List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+}
+
+func TestCreateAccountAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	users := userBuilder(c.client, c.state)
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"role":        "admin",
+		"vault_roles": map[string]interface{}{"tntlive": "write"},
+	})
+	assert.Nil(t, err)
+	frank := &v2.AccountInfo{Emails: []*v2.AccountInfo_Email{{Address: "frank@example.com", IsPrimary: true}}, Profile: profile}
+
+	res, _, _, err := users.CreateAccount(ctx, frank, nil)
+	assert.Nil(t, err)
+	pending, ok := res.(*v2.CreateAccountResponse_ActionRequiredResult)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, resourceTypeInvite.Id, pending.Resource.Id.ResourceType)
+	invite, _ := s.Invite(pending.Resource.Id.Resource)
+	assert.Equal(t, "ADMIN", invite.Role)
+	assert.Equal(t, []vgsfake.InviteVault{{Id: "tntlive", Role: "write"}}, invite.Vaults)
+
+	// Asking again returns the pending invite instead of sending another one.
+	res, _, _, err = users.CreateAccount(ctx, frank, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, pending.Resource.Id.Resource, res.(*v2.CreateAccountResponse_ActionRequiredResult).Resource.Id.Resource)
+	s.AssertCallCount(t, http.MethodPost, "/organizations/ACorg1/invites", 1)
+
+	// Once the pending invite is gone a new one is sent, however recently the invites were read.
+	_, err = c.client.CancelInvite(ctx, "ACorg1", pending.Resource.Id.Resource)
+	assert.Nil(t, err)
+	res, _, _, err = users.CreateAccount(ctx, frank, nil)
+	assert.Nil(t, err)
+	if reinvited, ok := res.(*v2.CreateAccountResponse_ActionRequiredResult); assert.True(t, ok) {
+		assert.NotEqual(t, pending.Resource.Id.Resource, reinvited.Resource.Id.Resource)
+	}
+	s.AssertCallCount(t, http.MethodPost, "/organizations/ACorg1/invites", 2)
+
+	// Existing members are returned as they are.
+	res, _, _, err = users.CreateAccount(ctx, &v2.AccountInfo{Login: "BOB@example.com"}, nil)
+	assert.Nil(t, err)
+	existing, ok := res.(*v2.CreateAccountResponse_SuccessResult)
+	if assert.True(t, ok) {
+		assert.Equal(t, "IDbob", existing.Resource.Id.Resource)
+	}
+	s.AssertCallCount(t, http.MethodPost, "/organizations/ACorg1/invites", 2)
+
+	// Inviting an email whose earlier invite expired sends a new one.
+	res, _, _, err = users.CreateAccount(ctx, &v2.AccountInfo{Login: "erin@example.com"}, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "INVexpired", res.(*v2.CreateAccountResponse_ActionRequiredResult).Resource.Id.Resource)
+}
+
+func TestDeleteUserAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	s.Seed(vgsfake.Fixture{Invites: []vgsfake.Invite{
+		{OrganizationId: "ACorg1", Id: "INValice", Email: "Alice@example.com", Status: "PENDING", Role: "MEMBER"},
+		{OrganizationId: "ACorg1", Id: "INValiceold", Email: "alice@example.com", Status: "EXPIRED", Role: "MEMBER"},
+	}})
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	users := userBuilder(c.client, c.state)
+
+	annos, err := users.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDalice"})
+	assert.Nil(t, err)
+	_, ok := s.Member("ACorg1", "IDalice")
+	assert.False(t, ok)
+	s.AssertCalled(t, http.MethodDelete, "/vaults/tntsandbox/members/IDalice")
+	s.AssertCalled(t, http.MethodDelete, "/vaults/tntlive/members/IDalice")
+	s.AssertCalled(t, http.MethodDelete, "/organizations/ACorg1/invites/INValice")
+	_, ok = s.Invite("INValiceold")
+	assert.True(t, ok)
+
+	report := &structpb.Struct{}
+	ok, err = annos.Pick(report)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"organization_id": "ACorg1",
+		"user_id":         "IDalice",
+		"email":           "alice@example.com",
+		"vault_memberships_removed": []interface{}{
+			map[string]interface{}{"vault_id": "tntsandbox", "role": "admin"},
+			map[string]interface{}{"vault_id": "tntlive", "role": "admin"},
+		},
+		"organization_membership_removed": true,
+		"invites_cancelled":               []interface{}{"INValice"},
+	}, report.AsMap())
+
+	// Deleting again finds nothing left to remove.
+	annos, err = users.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDalice"})
+	assert.Nil(t, err)
+	ok, err = annos.Pick(report)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, false, report.AsMap()["organization_membership_removed"])
+	s.AssertCallCount(t, http.MethodDelete, "/organizations/ACorg1/members/IDalice", 1)
+}
+
+func TestDeleteInviteAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	invites := inviteBuilder(c.client, c.state, nil)
+	invite := func(id string) *v2.ResourceId {
+		return &v2.ResourceId{ResourceType: resourceTypeInvite.Id, Resource: id}
+	}
+
+	// Deleting a pending user cancels their invite.
+	_, err := invites.Delete(ctx, invite("INVpending"))
+	assert.Nil(t, err)
+	_, ok := s.Invite("INVpending")
+	assert.False(t, ok)
+	s.AssertCalled(t, http.MethodDelete, "/organizations/ACorg1/invites/INVpending")
+
+	// Invites that are gone or no longer pending are left as they are.
+	_, err = invites.Delete(ctx, invite("INVpending"))
+	assert.Nil(t, err)
+	_, err = invites.Delete(ctx, invite("INVexpired"))
+	assert.Nil(t, err)
+	s.AssertCallCount(t, http.MethodDelete, "/organizations/ACorg1/invites/INVpending", 1)
+	s.AssertNotCalled(t, http.MethodDelete, "/organizations/ACorg1/invites/INVexpired")
+}
+
+func TestVaultRolesAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	s.Seed(vgsfake.Fixture{VaultMembers: []vgsfake.VaultMember{
+		{VaultId: "tntsandbox", UserId: "IDcarol", Role: "READ"},
+		{VaultId: "tntlive", UserId: "IDbob", Role: "auditor"},
+	}})
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	vaults := vaultBuilder(c.client)
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+
+	rs, _, _, err := vaults.List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	slugs := func(r *v2.Resource) []string {
+		entitlements, _, _, err := vaults.Entitlements(ctx, r, &pagination.Token{})
+		assert.Nil(t, err)
+		var rv []string
+		for _, e := range entitlements {
+			rv = append(rv, e.Slug)
+			assert.Equal(t, resourceTypeUser.Id, e.GrantableTo[0].Id)
+		}
+		return rv
+	}
+	assert.Equal(t, []string{"read", "write", "admin"}, slugs(rs[0]))
+	assert.Equal(t, []string{"read", "write", "admin", "auditor"}, slugs(rs[1]))
+
+	// A role that shows up after the entitlements were published is skipped.
+	s.Seed(vgsfake.Fixture{VaultMembers: []vgsfake.VaultMember{{VaultId: "tntsandbox", UserId: "IDodd", Role: "owner"}}})
+	grants, _, _, err := vaults.Grants(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+	roles := map[string]string{}
+	for _, g := range grants {
+		_, parts, err := parseEntitlementID(g.Entitlement.Id)
+		assert.Nil(t, err)
+		roles[g.Principal.Id.Resource] = parts[len(parts)-1]
+	}
+	assert.Equal(t, map[string]string{"IDalice": "admin", "IDbob": "write", "IDcarol": "read"}, roles)
+}
+
+func TestServiceAccountsAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	serviceAccounts := serviceAccountBuilder(c.client, c.state, false, false)
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+
+	rs, _, _, err := serviceAccounts.List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+
+	rs, _, _, err = serviceAccounts.List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	if !assert.Len(t, rs, 2) {
+		return
+	}
+	assert.Equal(t, "ACbbb-admin", rs[1].Id.Resource)
+	assert.Equal(t, "baton-admin", rs[1].DisplayName)
+	assert.Equal(t, org, rs[1].ParentResourceId)
+
+	trait, err := rsutil.GetUserTrait(rs[0])
+	assert.Nil(t, err)
+	assert.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SERVICE, trait.AccountType)
+	assert.Equal(t, true, trait.Profile.AsMap()["used_by_connector"])
+	assert.Equal(t, "alice@example.com", trait.Profile.AsMap()["created_by"])
+
+	entitlements, _, _, err := serviceAccounts.Entitlements(ctx, rs[1], &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, entitlements, 7)
+	grants, _, _, err := serviceAccounts.Grants(ctx, rs[1], &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Len(t, grants, 7) {
+		assert.Equal(t, entitlements[6].Id, grants[6].Entitlement.Id)
+		assert.Equal(t, "ACbbb-admin", grants[6].Principal.Id.Resource)
+	}
+
+	// Without the service-accounts:read scope service accounts are skipped rather than failing the sync.
+	s.Seed(vgsfake.Fixture{Clients: []vgsfake.Client{{Id: "ACccc-users", Secret: "users", Scopes: []string{vgsfake.ScopeOrganizationUsersRead}}}})
+	c = newFakeConnectorForTesting(t, s, "ACccc-users", "users")
+	s.ResetCalls()
+	rs, _, _, err = serviceAccountBuilder(c.client, c.state, false, false).List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+	s.AssertNotCalled(t, http.MethodGet, "/organizations/ACorg1/service-accounts")
+}
+
+func TestRotateServiceAccountSecretAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	reader := &v2.ResourceId{ResourceType: resourceTypeServiceAccount.Id, Resource: "ACaaa-reader"}
+	admin := &v2.ResourceId{ResourceType: resourceTypeServiceAccount.Id, Resource: "ACbbb-admin"}
+
+	plaintexts, _, err := serviceAccountBuilder(c.client, c.state, false, false).Rotate(ctx, reader, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	if !assert.Len(t, plaintexts, 1) {
+		return
+	}
+	assert.Equal(t, "client_secret", plaintexts[0].Name)
+	sa, _ := s.Client("ACaaa-reader")
+	assert.Equal(t, string(plaintexts[0].Bytes), sa.Secret)
+	assert.Equal(t, []string{"reader-secret"}, sa.PreviousSecrets)
+
+	plaintexts, _, err = serviceAccountBuilder(c.client, c.state, true, false).Rotate(ctx, reader, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	sa, _ = s.Client("ACaaa-reader")
+	assert.Equal(t, string(plaintexts[0].Bytes), sa.Secret)
+	assert.Empty(t, sa.PreviousSecrets)
+
+	// The connector's own secret is only rotated when explicitly allowed.
+	s.ResetCalls()
+	_, _, err = serviceAccountBuilder(c.client, c.state, true, false).Rotate(ctx, admin, &v2.CredentialOptions{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	s.AssertNotCalled(t, http.MethodPost, "/organizations/ACorg1/service-accounts/ACbbb-admin/secrets")
+
+	_, _, err = serviceAccountBuilder(c.client, c.state, false, true).Rotate(ctx, admin, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	sa, _ = s.Client("ACbbb-admin")
+	assert.Equal(t, []string{"admin-secret"}, sa.PreviousSecrets)
+
+	// Rotating requires the service-accounts:write scope.
+	c = newFakeConnectorForTesting(t, s, "ACaaa-reader", string(plaintexts[0].Bytes))
+	_, _, err = serviceAccountBuilder(c.client, c.state, false, false).Rotate(ctx, admin, &v2.CredentialOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestVaultCredentialsAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	credentials := vaultCredentialBuilder(c.client, c.state, false)
+	sandbox := &v2.ResourceId{ResourceType: resourceTypeVault.Id, Resource: "tntsandbox"}
+
+	rs, _, _, err := credentials.List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+
+	rs, _, _, err = credentials.List(ctx, sandbox, &pagination.Token{})
+	assert.Nil(t, err)
+	if !assert.Len(t, rs, 2) {
+		return
+	}
+	assert.Equal(t, "tntsandbox/USalice1", rs[0].Id.Resource)
+	assert.Equal(t, "USalice1", rs[0].DisplayName)
+	assert.Equal(t, sandbox, rs[0].ParentResourceId)
+
+	trait, err := rsutil.GetUserTrait(rs[0])
+	assert.Nil(t, err)
+	assert.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SERVICE, trait.AccountType)
+	assert.Equal(t, "alice@example.com", trait.Profile.AsMap()["created_by"])
+	assert.Equal(t, "2024-02-01T10:00:00Z", trait.Profile.AsMap()["created_at"])
+
+	entitlements, _, _, err := credentials.Entitlements(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, entitlements, 1)
+	grants, _, _, err := credentials.Grants(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Len(t, grants, 1) {
+		assert.Equal(t, entitlements[0].Id, grants[0].Entitlement.Id)
+		assert.Equal(t, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDalice"}, grants[0].Principal.Id)
+	}
+
+	// Credentials created by someone who left the organization have no creator grant.
+	grants, _, _, err = credentials.Grants(ctx, rs[1], &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, grants)
+
+	// Members are read once per sync and reused to resolve the creators of every vault's credentials.
+	rs, _, _, err = credentials.List(ctx, &v2.ResourceId{ResourceType: resourceTypeVault.Id, Resource: "tntlive"}, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, rs, 1)
+	s.AssertCallCount(t, http.MethodGet, "/organizations/ACorg1/members", 1)
+
+	// The next sync reads them again, so a creator who since left the organization has no grant.
+	_, err = c.client.RemoveUserOrganization(ctx, "ACorg1", "IDalice")
+	assert.Nil(t, err)
+	_, err = c.Validate(ctx)
+	assert.Nil(t, err)
+	rs, _, _, err = credentials.List(ctx, sandbox, &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Len(t, rs, 2) {
+		grants, _, _, err = credentials.Grants(ctx, rs[0], &pagination.Token{})
+		assert.Nil(t, err)
+		assert.Empty(t, grants)
+	}
+
+	// Without access to the organization members the credentials are listed without creators.
+	c = newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	s.FailNext(http.MethodGet, "/organizations/ACorg1/members", http.StatusForbidden, 1)
+	credentials = vaultCredentialBuilder(c.client, c.state, false)
+	rs, _, _, err = credentials.List(ctx, sandbox, &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Len(t, rs, 2) {
+		grants, _, _, err = credentials.Grants(ctx, rs[0], &pagination.Token{})
+		assert.Nil(t, err)
+		assert.Empty(t, grants)
+	}
+
+	// Without the credentials:read scope vault credentials are skipped rather than failing the sync.
+	c = newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	s.ResetCalls()
+	rs, _, _, err = vaultCredentialBuilder(c.client, c.state, false).List(ctx, sandbox, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+	s.AssertNotCalled(t, http.MethodGet, vgsfake.VaultManagementPath+"/vaults/tntsandbox/credentials")
+}
+
+func TestRotateAndDeleteVaultCredentialAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	credentials := vaultCredentialBuilder(c.client, c.state, false)
+	alice := &v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id, Resource: "tntsandbox/USalice1"}
+
+	plaintexts, _, err := credentials.Rotate(ctx, alice, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	if !assert.Len(t, plaintexts, 2) {
+		return
+	}
+	assert.Equal(t, "username", plaintexts[0].Name)
+	assert.Equal(t, "password", plaintexts[1].Name)
+	replacement, ok := s.VaultCredential("tntsandbox", string(plaintexts[0].Bytes))
+	assert.True(t, ok)
+	assert.Equal(t, string(plaintexts[1].Bytes), replacement.Secret)
+
+	// The replaced credential keeps working, unless the connector revokes previous secrets.
+	_, ok = s.VaultCredential("tntsandbox", "USalice1")
+	assert.True(t, ok)
+	s.AssertNotCalled(t, http.MethodDelete, vgsfake.VaultManagementPath+"/vaults/tntsandbox/credentials/USalice1")
+
+	rotationReport := func(annos annotations.Annotations) map[string]interface{} {
+		report := &structpb.Struct{}
+		for _, a := range annos {
+			if a.MessageIs(report) && assert.Nil(t, a.UnmarshalTo(report)) {
+				return report.AsMap()
+			}
+		}
+		return nil
+	}
+
+	revoking := vaultCredentialBuilder(c.client, c.state, true)
+	_, annos, err := revoking.Rotate(ctx, alice, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	_, ok = s.VaultCredential("tntsandbox", "USalice1")
+	assert.False(t, ok)
+	assert.Equal(t, true, rotationReport(annos)["replaced_credential_revoked"])
+
+	// A replaced credential that cannot be revoked is reported, and the new credential is still returned.
+	replaced := string(plaintexts[0].Bytes)
+	s.FailNext(http.MethodDelete, vgsfake.VaultManagementPath+"/vaults/tntsandbox/credentials/"+replaced, http.StatusForbidden, 1)
+	plaintexts, annos, err = revoking.Rotate(ctx,
+		&v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id, Resource: "tntsandbox/" + replaced}, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, plaintexts, 2)
+	_, ok = s.VaultCredential("tntsandbox", replaced)
+	assert.True(t, ok)
+	report := rotationReport(annos)
+	assert.Equal(t, false, report["replaced_credential_revoked"])
+	assert.Equal(t, replaced, report["credential_id"])
+	assert.Contains(t, report["revoke_error"], "403")
+
+	// A leaked credential is revoked outright, and revoking it again succeeds.
+	former := &v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id, Resource: "tntsandbox/USformer"}
+	_, err = credentials.Delete(ctx, former)
+	assert.Nil(t, err)
+	_, ok = s.VaultCredential("tntsandbox", "USformer")
+	assert.False(t, ok)
+	_, err = credentials.Delete(ctx, former)
+	assert.Nil(t, err)
+
+	_, err = credentials.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id, Resource: "USbob1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, ok = s.VaultCredential("tntlive", "USbob1")
+	assert.True(t, ok)
+
+	// Rotating and revoking require the credentials:write scope.
+	c = newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	_, err = vaultCredentialBuilder(c.client, c.state, false).Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id, Resource: "tntlive/USbob1"})
+	assert.NotNil(t, err)
+	_, ok = s.VaultCredential("tntlive", "USbob1")
+	assert.True(t, ok)
+}
+
+func TestVaultGrantAndRevokeAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	vaults := vaultBuilder(c.client)
+
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+	rs, _, _, err := vaults.List(ctx, org, &pagination.Token{})
+	assert.Nil(t, err)
+	entitlements, _, _, err := vaults.Entitlements(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+
+	admin := vaultEntitlementForTesting(t, entitlements, vaultRoleAdmin)
+
+	bob := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDbob"}}
+	grants, _, err := vaults.Grant(ctx, bob, admin)
+	assert.Nil(t, err)
+	if assert.Len(t, grants, 1) {
+		assert.Equal(t, admin.Id, grants[0].Entitlement.Id)
+		assert.Equal(t, "IDbob", grants[0].Principal.Id.Resource)
+	}
+	member, _ := s.VaultMember("tntsandbox", "IDbob")
+	assert.Equal(t, vaultRoleAdmin, member.Role)
+	s.AssertCalled(t, http.MethodPut, "/vaults/tntsandbox/members/IDbob")
+
+	// Organization members without a vault membership are added to the vault.
+	carol := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDcarol"}}
+	write := vaultEntitlementForTesting(t, entitlements, vaultRoleWrite)
+	_, _, err = vaults.Grant(ctx, carol, write)
+	assert.Nil(t, err)
+	member, _ = s.VaultMember("tntsandbox", "IDcarol")
+	assert.Equal(t, vaultRoleWrite, member.Role)
+	s.AssertCalled(t, http.MethodPost, "/vaults/tntsandbox/members")
+
+	grants, annos, err := vaults.Grant(ctx, carol, write)
+	assert.Nil(t, err)
+	assert.Len(t, grants, 1)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+	s.AssertCallCount(t, http.MethodPost, "/vaults/tntsandbox/members", 1)
+	s.AssertNotCalled(t, http.MethodPut, "/vaults/tntsandbox/members/IDcarol")
+
+	// Revoking admin keeps write access.
+	_, err = vaults.Revoke(ctx, &v2.Grant{Principal: bob, Entitlement: admin})
+	assert.Nil(t, err)
+	member, _ = s.VaultMember("tntsandbox", "IDbob")
+	assert.Equal(t, vaultRoleWrite, member.Role)
+
+	// Revoking a role that is not held changes nothing.
+	annos, err = vaults.Revoke(ctx, &v2.Grant{Principal: bob, Entitlement: admin})
+	assert.Nil(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	s.AssertCallCount(t, http.MethodPut, "/vaults/tntsandbox/members/IDbob", 2)
+
+	// Revoking write keeps read access, revoking read removes the membership.
+	_, err = vaults.Revoke(ctx, &v2.Grant{Principal: bob, Entitlement: write})
+	assert.Nil(t, err)
+	member, _ = s.VaultMember("tntsandbox", "IDbob")
+	assert.Equal(t, vaultRoleRead, member.Role)
+	s.AssertCallCount(t, http.MethodPut, "/vaults/tntsandbox/members/IDbob", 3)
+	s.AssertNotCalled(t, http.MethodDelete, "/vaults/tntsandbox/members/IDbob")
+
+	_, err = vaults.Revoke(ctx, &v2.Grant{Principal: bob, Entitlement: vaultEntitlementForTesting(t, entitlements, vaultRoleRead)})
+	assert.Nil(t, err)
+	_, ok := s.VaultMember("tntsandbox", "IDbob")
+	assert.False(t, ok)
+
+	annos, err = vaults.Revoke(ctx, &v2.Grant{Principal: bob, Entitlement: admin})
+	assert.Nil(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	s.AssertCallCount(t, http.MethodDelete, "/vaults/tntsandbox/members/IDbob", 1)
+
+	// Revoke acts on the role held now, not the one an earlier read saw.
+	annos, err = vaults.Revoke(ctx, &v2.Grant{Principal: carol, Entitlement: admin})
+	assert.Nil(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	_, err = c.client.UpdateUserAccessVault(ctx, "tntsandbox", "IDcarol", vaultRoleAdmin)
+	assert.Nil(t, err)
+	annos, err = vaults.Revoke(ctx, &v2.Grant{Principal: carol, Entitlement: admin})
+	assert.Nil(t, err)
+	assert.False(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	member, _ = s.VaultMember("tntsandbox", "IDcarol")
+	assert.Equal(t, vaultRoleWrite, member.Role)
+}
+
+func vaultEntitlementForTesting(t *testing.T, entitlements []*v2.Entitlement, role string) *v2.Entitlement {
+	for _, e := range entitlements {
+		if e.Slug == role {
+			return e
+		}
+	}
+
+	t.Fatalf("no %s entitlement", role)
+	return nil
+}
+
+func TestOrgGrantsAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting, vgsfake.WithMaxPageSize(2))
+	s.Seed(vgsfake.Fixture{Members: []vgsfake.Member{{OrganizationId: "ACorg1", Id: "IDodd", Email: "odd@example.com", Role: "OWNER"}}})
+	c := newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	orgs := orgBuilder(c.client, c.state)
+
+	rs, _, _, err := orgs.List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Len(t, rs, 1)
+
+	roles := map[string]string{}
+	token := &pagination.Token{}
+	for {
+		grants, next, _, err := orgs.Grants(ctx, rs[0], token)
+		assert.Nil(t, err)
+		for _, g := range grants {
+			_, parts, err := parseEntitlementID(g.Entitlement.Id)
+			assert.Nil(t, err)
+			roles[g.Principal.Id.Resource] = parts[len(parts)-1]
+		}
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+
+	// The member with a role the connector does not know is skipped.
+	assert.Equal(t, map[string]string{
+		"IDalice": orgRoleAdmin,
+		"IDbob":   orgRoleMember,
+		"IDcarol": orgRoleMember,
+	}, roles)
+}
+
+func orgEntitlementForTesting(t *testing.T, orgs *orgResourceType, role string) *v2.Entitlement {
+	rs, _, _, err := orgs.List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	entitlements, _, _, err := orgs.Entitlements(ctx, rs[0], &pagination.Token{})
+	assert.Nil(t, err)
+	for _, e := range entitlements {
+		if e.Slug == role {
+			return e
+		}
+	}
+
+	t.Fatalf("no %s entitlement", role)
+	return nil
+}
+
+func TestOrgGrantAndRevokeAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	c := newFakeConnectorForTesting(t, s, "ACbbb-admin", "admin-secret")
+	orgs := orgBuilder(c.client, c.state)
+	admin := orgEntitlementForTesting(t, orgs, orgRoleAdmin)
+	member := orgEntitlementForTesting(t, orgs, orgRoleMember)
+	user := func(id string) *v2.Resource {
+		return &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: id}}
+	}
+
+	// member -> admin
+	_, err := orgs.Grant(ctx, user("IDbob"), admin)
+	assert.Nil(t, err)
+	m, _ := s.Member("ACorg1", "IDbob")
+	assert.Equal(t, "ADMIN", m.Role)
+
+	// Granting a role the user already holds changes nothing.
+	annos, err := orgs.Grant(ctx, user("IDbob"), admin)
+	assert.Nil(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+	s.AssertCallCount(t, http.MethodPut, "/organizations/ACorg1/members/IDbob", 1)
+
+	// Grant and Revoke act on the role held now, not the one an earlier read saw.
+	_, err = c.client.UpdateUserRoleOrganization(ctx, "ACorg1", "IDbob", orgRoleMember)
+	assert.Nil(t, err)
+	annos, err = orgs.Grant(ctx, user("IDbob"), admin)
+	assert.Nil(t, err)
+	assert.False(t, annos.Contains(&v2.GrantAlreadyExists{}))
+	m, _ = s.Member("ACorg1", "IDbob")
+	assert.Equal(t, "ADMIN", m.Role)
+
+	// Revoking admin keeps the user in the organization as a member.
+	_, err = orgs.Revoke(ctx, &v2.Grant{Principal: user("IDbob"), Entitlement: admin})
+	assert.Nil(t, err)
+	m, _ = s.Member("ACorg1", "IDbob")
+	assert.Equal(t, "MEMBER", m.Role)
+
+	// Revoking member removes the user from the organization's vaults, then from the organization.
+	_, err = orgs.Revoke(ctx, &v2.Grant{Principal: user("IDbob"), Entitlement: member})
+	assert.Nil(t, err)
+	_, ok := s.Member("ACorg1", "IDbob")
+	assert.False(t, ok)
+	_, ok = s.VaultMember("tntsandbox", "IDbob")
+	assert.False(t, ok)
+	s.AssertCalled(t, http.MethodDelete, "/vaults/tntsandbox/members/IDbob")
+
+	annos, err = orgs.Revoke(ctx, &v2.Grant{Principal: user("IDbob"), Entitlement: member})
+	assert.Nil(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+
+	// Users outside the organization have to be invited.
+	_, err = orgs.Grant(ctx, user("IDbob"), member)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	s.Seed(vgsfake.Fixture{Members: []vgsfake.Member{{OrganizationId: "ACorg1", Id: "IDowner", Role: "OWNER"}}})
+	_, err = orgs.Grant(ctx, user("IDowner"), admin)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = orgs.Revoke(ctx, &v2.Grant{Principal: user("IDowner"), Entitlement: member})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	s.AssertNotCalled(t, http.MethodPut, "/organizations/ACorg1/members/IDowner")
+}
+
+func TestListEventsAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting, vgsfake.WithMaxPageSize(3))
+	s.Seed(vgsfake.Fixture{Clients: []vgsfake.Client{{
+		Id:     "ACddd-audit",
+		Secret: "audit",
+		Scopes: []string{vgsfake.ScopeOrganizationUsersRead, vgsfake.ScopeAuditLogsRead},
+	}}})
+	c := newFakeConnectorForTesting(t, s, "ACddd-audit", "audit")
+
+	stream := func(earliest *timestamppb.Timestamp, cursor string) ([]*v2.Event, string) {
+		var all []*v2.Event
+		for {
+			events, state, _, err := c.ListEvents(ctx, earliest, &pagination.StreamToken{Cursor: cursor})
+			if !assert.Nil(t, err) {
+				return nil, ""
+			}
+			all = append(all, events...)
+			cursor = state.Cursor
+			if !state.HasMore {
+				return all, cursor
+			}
+		}
+	}
+
+	events, cursor := stream(nil, "")
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.Id)
+	}
+	assert.Equal(t, []string{"EVTaccept", "EVTlogin", "EVTvaultadd", "EVTvaultrole", "EVTorgrole", "EVTvaultremove", "EVTorgremove"}, ids)
+	if len(events) != 7 {
+		return
+	}
+
+	accepted := events[0].GetGrantEvent().GetGrant()
+	assert.Equal(t, "org:ACorg1:member", accepted.Entitlement.Id)
+	assert.Equal(t, "IDcarol", accepted.Principal.Id.Resource)
+	login := events[1].GetUsageEvent()
+	assert.Equal(t, "ACorg1", login.TargetResource.Id.Resource)
+	assert.Equal(t, "IDcarol", login.ActorResource.Id.Resource)
+	assert.Equal(t, "vault:tntsandbox:read", events[2].GetGrantEvent().GetGrant().Entitlement.Id)
+	removed := events[5].GetRevokeEvent()
+	assert.Equal(t, "vault:tntlive:admin", removed.Entitlement.Id)
+	assert.Equal(t, "IDmallory", removed.Principal.Id.Resource)
+	assert.Equal(t, "org:ACorg1:member", events[6].GetRevokeEvent().Entitlement.Id)
+
+	// Resuming returns nothing until new entries are logged, including entries logged at the time of the last one.
+	events, cursor = stream(nil, cursor)
+	assert.Empty(t, events)
+	s.Seed(vgsfake.Fixture{AuditLogs: []vgsfake.AuditLog{{
+		OrganizationId: "ACorg1",
+		Id:             "EVTlate",
+		Action:         "user.login",
+		OccurredAt:     "2024-03-05T08:00:00Z",
+		ActorId:        "IDbob",
+	}}})
+	events, _ = stream(nil, cursor)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "EVTlate", events[0].Id)
+	}
+
+	// The earliest event cutoff is passed on to the audit log.
+	s.ResetCalls()
+	events, _ = stream(timestamppb.New(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)), "")
+	assert.Len(t, events, 5)
+	calls := s.CallsTo(http.MethodGet, "/organizations/ACorg1/audit-logs")
+	if assert.NotEmpty(t, calls) {
+		assert.Equal(t, "2024-03-04T00:00:00Z", calls[0].Query.Get("filter[since]"))
+		assert.Equal(t, "occurred_at", calls[0].Query.Get("sort"))
+	}
+
+	// The event feed requires the audit-logs:read scope.
+	c = newFakeConnectorForTesting(t, s, "ACaaa-reader", "reader-secret")
+	_, _, _, err := c.ListEvents(ctx, nil, &pagination.StreamToken{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// seedStagingOrganization adds a second organization reachable by ACeee-multi, an ACorg1 service account, and a
+// third one it cannot reach.
+func seedStagingOrganization(s *vgsfake.Server) {
+	s.Seed(vgsfake.Fixture{
+		Clients: []vgsfake.Client{
+			{
+				Id:             "ACeee-multi",
+				Secret:         "multi",
+				OrganizationId: "ACorg1",
+				Organizations:  []string{"ACorg2"},
+				Scopes: []string{
+					vgsfake.ScopeOrganizationUsersRead,
+					vgsfake.ScopeOrganizationUsersWrite,
+					vgsfake.ScopeServiceAccountsRead,
+					vgsfake.ScopeServiceAccountsWrite,
+					vgsfake.ScopeAuditLogsRead,
+				},
+			},
+			{Id: "ACfff-staging", Secret: "staging", OrganizationId: "ACorg2", Name: "staging"},
+		},
+		Organizations: []vgsfake.Organization{
+			{Id: "ACorg2", Name: "Acme Staging", State: "ACTIVE"},
+			{Id: "ACorg3", Name: "Elsewhere", State: "ACTIVE"},
+		},
+		Members: []vgsfake.Member{
+			{OrganizationId: "ACorg2", Id: "IDalice", Name: "Alice Admin", Email: "alice@example.com", Role: "ADMIN"},
+			{OrganizationId: "ACorg2", Id: "IDfrank", Name: "Frank", Email: "frank@example.com", Role: "MEMBER"},
+			{OrganizationId: "ACorg3", Id: "IDzoe", Email: "zoe@example.com", Role: "MEMBER"},
+		},
+		Invites: []vgsfake.Invite{
+			{OrganizationId: "ACorg2", Id: "INVstaging", Email: "gina@example.com", Status: "PENDING", Role: "MEMBER"},
+		},
+		Vaults: []vgsfake.Vault{
+			{OrganizationId: "ACorg2", Id: "tntstaging", Name: "Staging"},
+			{OrganizationId: "ACorg3", Id: "tntelsewhere", Name: "Elsewhere"},
+		},
+		VaultMembers: []vgsfake.VaultMember{
+			{VaultId: "tntstaging", UserId: "IDalice", Role: "admin"},
+			{VaultId: "tntstaging", UserId: "IDfrank", Role: "read"},
+		},
+		AuditLogs: []vgsfake.AuditLog{{
+			OrganizationId: "ACorg2",
+			Id:             "EVTstaging",
+			Action:         "vault.member_added",
+			OccurredAt:     "2024-03-03T11:00:00Z",
+			UserId:         "IDfrank",
+			VaultId:        "tntstaging",
+			Role:           "read",
+		}},
+	})
+}
+
+func TestMultiOrganizationSyncAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	seedStagingOrganization(s)
+	c := newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi")
+
+	_, err := c.Validate(ctx)
+	assert.Nil(t, err)
+
+	// Without configured organizations every reachable organization is synced.
+	ids := func(rs []*v2.Resource) []string {
+		var ids []string
+		for _, r := range rs {
+			ids = append(ids, r.Id.Resource)
+		}
+		return ids
+	}
+	orgs, _, _, err := orgBuilder(c.client, c.state).List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ACorg1", "ACorg2"}, ids(orgs))
+
+	// Members of several organizations are listed once, under the first of them: IDalice under ACorg1.
+	production := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg1"}
+	users, _, _, err := userBuilder(c.client, c.state).List(ctx, production, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Contains(t, ids(users), "IDalice")
+
+	staging := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg2"}
+	users, _, _, err = userBuilder(c.client, c.state).List(ctx, staging, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"IDfrank"}, ids(users))
+	if len(users) > 0 {
+		assert.Equal(t, staging, users[0].ParentResourceId)
+	}
+
+	invites, _, _, err := inviteBuilder(c.client, c.state, nil).List(ctx, staging, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"INVstaging"}, ids(invites))
+
+	vaults := vaultBuilder(c.client)
+	rs, _, _, err := vaults.List(ctx, staging, &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Equal(t, []string{"tntstaging"}, ids(rs)) {
+		grants, _, _, err := vaults.Grants(ctx, rs[0], &pagination.Token{})
+		assert.Nil(t, err)
+		assert.Len(t, grants, 2)
+	}
+
+	// Events are read from the audit log of each organization in turn, looking the organizations up once.
+	s.ResetCalls()
+	var (
+		events []string
+		cursor string
+	)
+	for {
+		page, state, _, err := c.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: cursor})
+		if !assert.Nil(t, err) {
+			break
+		}
+		for _, e := range page {
+			events = append(events, e.Id)
+		}
+		cursor = state.Cursor
+		if !state.HasMore {
+			break
+		}
+	}
+	assert.Contains(t, events, "EVTaccept")
+	assert.Contains(t, events, "EVTstaging")
+	s.AssertCalled(t, http.MethodGet, "/organizations/ACorg2/audit-logs")
+	s.AssertCallCount(t, http.MethodGet, "/organizations", 1)
+
+	// Configured organizations limit the sync.
+	c = newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi", "ACorg2")
+	orgs, _, _, err = orgBuilder(c.client, c.state).List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ACorg2"}, ids(orgs))
+	users, _, _, err = userBuilder(c.client, c.state).List(ctx, staging, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"IDalice", "IDfrank"}, ids(users))
+
+	// An organization the service account cannot read fails validation when configured, and its members and
+	// invites are skipped during a sync.
+	c = newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi", "ACorg2", "ACorg3")
+	_, err = c.Validate(ctx)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.ErrorContains(t, err, "ACorg3")
+
+	elsewhere := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg3"}
+	users, _, _, err = userBuilder(c.client, c.state).List(ctx, elsewhere, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+	invites, _, _, err = inviteBuilder(c.client, c.state, nil).List(ctx, elsewhere, &pagination.Token{})
+	assert.Nil(t, err)
+	assert.Empty(t, invites)
+}
+
+func TestSyncReadsOrganizationsOnceAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting, vgsfake.WithMaxPageSize(1))
+	seedStagingOrganization(s)
+	c := newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi")
+	_, err := c.Validate(ctx)
+	assert.Nil(t, err)
+
+	// Every page of ACorg2 members reuses the organizations found by Validate, and the ACorg1 members are read once
+	// to leave out the users already listed there.
+	s.ResetCalls()
+	var (
+		users []string
+		token string
+	)
+	builder := userBuilder(c.client, c.state)
+	staging := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: "ACorg2"}
+	for {
+		page, next, _, err := builder.List(ctx, staging, &pagination.Token{Token: token})
+		if !assert.Nil(t, err) {
+			break
+		}
+		for _, u := range page {
+			users = append(users, u.Id.Resource)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, []string{"IDfrank"}, users)
+	s.AssertNotCalled(t, http.MethodGet, "/organizations")
+	s.AssertCallCount(t, http.MethodGet, "/organizations/ACorg2/members", 2)
+	// One request per ACorg1 member at a page size of one.
+	s.AssertCallCount(t, http.MethodGet, "/organizations/ACorg1/members", 3)
+}
+
+func TestUnreadableOrganizationAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	seedStagingOrganization(s)
+	c := newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi")
+
+	// A discovered organization whose members cannot be read is left out of validation, listing and offboarding.
+	s.FailNext(http.MethodGet, "/organizations/ACorg2/members", http.StatusForbidden, 100)
+	_, err := c.Validate(ctx)
+	assert.Nil(t, err)
+
+	orgIds, _, err := c.state.organizationIds(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ACorg1"}, orgIds)
+
+	orgs, _, _, err := orgBuilder(c.client, c.state).List(ctx, nil, &pagination.Token{})
+	assert.Nil(t, err)
+	if assert.Len(t, orgs, 1) {
+		assert.Equal(t, "ACorg1", orgs[0].Id.Resource)
+	}
+
+	_, err = userBuilder(c.client, c.state).Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDalice"})
+	assert.Nil(t, err)
+	_, ok := s.Member("ACorg2", "IDalice")
+	assert.True(t, ok)
+	s.AssertNotCalled(t, http.MethodDelete, "/organizations/ACorg2/members/IDalice")
+
+	// The same organization fails validation when it is configured.
+	c = newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi", "ACorg1", "ACorg2")
+	_, err = c.Validate(ctx)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestMultiOrganizationProvisioningAgainstFake(t *testing.T) {
+	s := vgsfake.NewFromFile(t, fixtureForTesting)
+	seedStagingOrganization(s)
+	c := newFakeConnectorForOrganizations(t, s, "ACeee-multi", "multi")
+	users := userBuilder(c.client, c.state)
+
+	// Invites name their organization once more than one is synced.
+	_, _, _, err := users.CreateAccount(ctx, &v2.AccountInfo{Login: "hank@example.com"}, nil)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	profile, err := structpb.NewStruct(map[string]interface{}{"organization_id": "ACorg2"})
+	assert.Nil(t, err)
+	res, _, _, err := users.CreateAccount(ctx, &v2.AccountInfo{Login: "hank@example.com", Profile: profile}, nil)
+	assert.Nil(t, err)
+	if invite, ok := res.(*v2.CreateAccountResponse_ActionRequiredResult); assert.True(t, ok) {
+		assert.Equal(t, "ACorg2", invite.Resource.ParentResourceId.Resource)
+	}
+	s.AssertCalled(t, http.MethodPost, "/organizations/ACorg2/invites")
+
+	profile, err = structpb.NewStruct(map[string]interface{}{"organization_id": "ACorg3"})
+	assert.Nil(t, err)
+	_, _, _, err = users.CreateAccount(ctx, &v2.AccountInfo{Login: "hank@example.com", Profile: profile}, nil)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Deleting a user offboards them from every synced organization, with a report for each.
+	annos, err := users.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "IDalice"})
+	assert.Nil(t, err)
+	_, ok := s.Member("ACorg1", "IDalice")
+	assert.False(t, ok)
+	_, ok = s.Member("ACorg2", "IDalice")
+	assert.False(t, ok)
+	s.AssertCalled(t, http.MethodDelete, "/vaults/tntstaging/members/IDalice")
+
+	var reported []interface{}
+	for _, a := range annos {
+		report := &structpb.Struct{}
+		if a.MessageIs(report) && assert.Nil(t, a.UnmarshalTo(report)) {
+			reported = append(reported, report.AsMap()["organization_id"])
+		}
+	}
+	assert.Equal(t, []interface{}{"ACorg1", "ACorg2"}, reported)
+
+	// Service account secrets are rotated in the organization the service account belongs to.
+	plaintexts, _, err := serviceAccountBuilder(c.client, c.state, false, false).Rotate(ctx,
+		&v2.ResourceId{ResourceType: resourceTypeServiceAccount.Id, Resource: "ACfff-staging"}, &v2.CredentialOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, plaintexts, 1)
+	s.AssertCalled(t, http.MethodPost, "/organizations/ACorg2/service-accounts/ACfff-staging/secrets")
+}
@@ -0,0 +1,276 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Audit log actions mapped into events. Other actions are skipped.
+const (
+	auditActionInviteAccepted        = "invite.accepted"
+	auditActionUserLogin             = "user.login"
+	auditActionOrgMemberRoleUpdated  = "organization.member_role_updated"
+	auditActionOrgMemberRemoved      = "organization.member_removed"
+	auditActionVaultMemberAdded      = "vault.member_added"
+	auditActionVaultMemberRoleUpdate = "vault.member_role_updated"
+	auditActionVaultMemberRemoved    = "vault.member_removed"
+)
+
+// eventStream is the resumable position of the event feed: the organizations of the current stream, the one read
+// next and the position in the audit log of each organization. Organizations are read one after another, and the
+// stream ends after the last one. The organizations are looked up again when the next stream starts.
+type eventStream struct {
+	Ids  []string                `json:"ids,omitempty"`
+	Org  string                  `json:"org,omitempty"`
+	Orgs map[string]*eventCursor `json:"orgs,omitempty"`
+}
+
+// eventCursor is the resumable position in an organization's audit log. Entries are listed oldest first from Since,
+// so page numbers stay stable while new entries are appended. Once the log is exhausted the next stream starts at
+// the latest entry returned, skipping the entries at that time that were already returned.
+type eventCursor struct {
+	Since string   `json:"since,omitempty"`
+	Skip  []string `json:"skip,omitempty"`
+	Page  string   `json:"page,omitempty"`
+	Last  string   `json:"last,omitempty"`
+	Seen  []string `json:"seen,omitempty"`
+}
+
+// ListEvents reads the audit log of every synced organization and maps membership changes, invite acceptances and
+// sign-ins into events. Entries that occurred before earliestEvent are skipped, as are organizations whose audit
+// log the service account cannot read.
+func (d *Connector) ListEvents(ctx context.Context, earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	stream, err := parseEventStream(pToken.Cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(stream.Ids) == 0 {
+		ids, rateLimit, err := listOrganizationIds(ctx, d.client)
+		if err != nil {
+			return nil, nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to fetch organizations")
+		}
+		if len(ids) == 0 {
+			return nil, &pagination.StreamState{Cursor: pToken.Cursor}, nil, nil
+		}
+		stream.Ids = ids
+	}
+	orgIds := stream.Ids
+
+	current := max(slices.Index(orgIds, stream.Org), 0)
+	orgId := orgIds[current]
+	cursor, ok := stream.Orgs[orgId]
+	if !ok {
+		cursor = &eventCursor{}
+		stream.Orgs[orgId] = cursor
+	}
+
+	var earliest time.Time
+	if earliestEvent != nil {
+		earliest = earliestEvent.AsTime()
+	}
+	since := earliest
+	if cursor.Since != "" {
+		since, err = time.Parse(time.RFC3339, cursor.Since)
+		if err != nil {
+			return nil, nil, nil, status.Errorf(codes.InvalidArgument, "baton-vgs: invalid event cursor: %v", err)
+		}
+	} else if !earliest.IsZero() {
+		cursor.Since = earliest.UTC().Format(time.RFC3339)
+	}
+
+	var events []*v2.Event
+	entries, nextPage, rateLimit, err := d.client.ListAuditLogs(ctx, orgId, since, cursor.Page)
+	annos := rateLimitAnnotations(rateLimit)
+	switch {
+	case isForbidden(err):
+		l.Warn("baton-vgs: skipping events, the service account cannot read the organization audit log",
+			zap.String("organization_id", orgId),
+			zap.Error(err),
+		)
+		entries, nextPage = nil, ""
+	case err != nil:
+		return nil, nil, annos, wrapError(err, fmt.Sprintf("baton-vgs: failed to fetch audit log of organization %s", orgId))
+	}
+
+	for _, entry := range entries {
+		occurredAt, err := time.Parse(time.RFC3339, entry.OccurredAt)
+		if err != nil {
+			l.Warn("baton-vgs: skipping audit log entry with invalid time",
+				zap.String("organization_id", orgId),
+				zap.String("event_id", entry.Id),
+				zap.String("occurred_at", entry.OccurredAt),
+			)
+			continue
+		}
+
+		if entry.OccurredAt == cursor.Since && slices.Contains(cursor.Skip, entry.Id) {
+			continue
+		}
+		cursor.observe(entry)
+
+		if occurredAt.Before(earliest) {
+			continue
+		}
+
+		event := auditLogEvent(orgId, entry, occurredAt)
+		if event == nil {
+			l.Debug("baton-vgs: skipping audit log entry", zap.String("event_id", entry.Id), zap.String("action", entry.Action))
+			continue
+		}
+		events = append(events, event)
+	}
+
+	cursor.Page = nextPage
+	hasMore := nextPage != ""
+	stream.Org = orgId
+	if !hasMore {
+		cursor.resume()
+		stream.Org = ""
+		if next := current + 1; next < len(orgIds) {
+			stream.Org = orgIds[next]
+			hasMore = true
+		} else {
+			stream.Ids = nil
+		}
+	}
+
+	token, err := json.Marshal(stream)
+	if err != nil {
+		return nil, nil, annos, err
+	}
+
+	return events, &pagination.StreamState{Cursor: string(token), HasMore: hasMore}, annos, nil
+}
+
+func parseEventStream(token string) (*eventStream, error) {
+	stream := &eventStream{}
+	if token != "" {
+		if err := json.Unmarshal([]byte(token), stream); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: invalid event cursor: %v", err)
+		}
+	}
+
+	if stream.Orgs == nil {
+		stream.Orgs = map[string]*eventCursor{}
+	}
+
+	return stream, nil
+}
+
+// observe records an entry as returned, so a later stream does not return it again.
+func (c *eventCursor) observe(entry client.AuditLogEntry) {
+	if entry.OccurredAt == c.Last {
+		c.Seen = append(c.Seen, entry.Id)
+		return
+	}
+
+	c.Last = entry.OccurredAt
+	c.Seen = []string{entry.Id}
+}
+
+// resume moves the cursor past the exhausted log, so the next stream starts at the latest entry returned.
+func (c *eventCursor) resume() {
+	if c.Last == "" {
+		return
+	}
+
+	if c.Last == c.Since {
+		c.Skip = append(c.Skip, c.Seen...)
+	} else {
+		c.Since = c.Last
+		c.Skip = c.Seen
+	}
+	c.Last = ""
+	c.Seen = nil
+}
+
+// auditLogEvent maps an audit log entry to an event, or returns nil when the entry is not one the connector reports.
+func auditLogEvent(orgId string, entry client.AuditLogEntry, occurredAt time.Time) *v2.Event {
+	org := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}}
+	event := &v2.Event{
+		Id:         entry.Id,
+		OccurredAt: timestamppb.New(occurredAt),
+	}
+
+	switch entry.Action {
+	case auditActionUserLogin:
+		if entry.ActorId == "" {
+			return nil
+		}
+		event.Event = &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				TargetResource: org,
+				ActorResource:  eventUserResource(entry.ActorId, entry.ActorEmail),
+			},
+		}
+	case auditActionInviteAccepted, auditActionOrgMemberRoleUpdated:
+		role := strings.ToLower(entry.Role)
+		if entry.UserId == "" || !slices.Contains(orgAccessLevels, role) {
+			return nil
+		}
+		event.Event = eventGrant(org, role, entry)
+	case auditActionOrgMemberRemoved:
+		role := strings.ToLower(entry.Role)
+		if entry.UserId == "" || !slices.Contains(orgAccessLevels, role) {
+			return nil
+		}
+		event.Event = eventRevoke(org, role, entry)
+	case auditActionVaultMemberAdded, auditActionVaultMemberRoleUpdate, auditActionVaultMemberRemoved:
+		if entry.UserId == "" || entry.VaultId == "" || entry.Role == "" {
+			return nil
+		}
+		vault := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeVault.Id, Resource: entry.VaultId}}
+		if entry.Action == auditActionVaultMemberRemoved {
+			event.Event = eventRevoke(vault, strings.ToLower(entry.Role), entry)
+		} else {
+			event.Event = eventGrant(vault, strings.ToLower(entry.Role), entry)
+		}
+	default:
+		return nil
+	}
+
+	return event
+}
+
+func eventGrant(resource *v2.Resource, role string, entry client.AuditLogEntry) *v2.Event_GrantEvent {
+	principal := eventUserResource(entry.UserId, entry.UserEmail)
+	return &v2.Event_GrantEvent{
+		GrantEvent: &v2.GrantEvent{
+			Grant: grant.NewGrant(resource, role, principal),
+		},
+	}
+}
+
+func eventRevoke(resource *v2.Resource, role string, entry client.AuditLogEntry) *v2.Event_RevokeEvent {
+	return &v2.Event_RevokeEvent{
+		RevokeEvent: &v2.RevokeEvent{
+			Entitlement: ent.NewPermissionEntitlement(resource, role, ent.WithGrantableTo(resourceTypeUser)),
+			Principal:   eventUserResource(entry.UserId, entry.UserEmail),
+		},
+	}
+}
+
+func eventUserResource(userId, email string) *v2.Resource {
+	return &v2.Resource{
+		Id:          &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: userId},
+		DisplayName: email,
+	}
+}
@@ -1,7 +1,9 @@
 package connector
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -12,6 +14,8 @@ import (
 	"github.com/conductorone/baton-vgs/pkg/client"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func annotationsForUserResourceType() annotations.Annotations {
@@ -127,3 +131,76 @@ func parseEntitlementID(id string) (*v2.ResourceId, []string, error) {
 
 	return resourceId, parts, nil
 }
+
+// grpcCodeForStatus maps an Accounts API status code to the gRPC code Baton expects.
+func grpcCodeForStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusTooManyRequests:
+		return codes.Unavailable
+	}
+
+	if statusCode >= http.StatusInternalServerError {
+		return codes.Unavailable
+	}
+
+	return codes.Unknown
+}
+
+// wrapError annotates err with message. VGS API errors are turned into gRPC status errors so Baton can tell
+// a missing permission from a missing object or a bad request. Rate limit data is attached as a status detail
+// so the SDK can wait for the limit to reset before retrying.
+func wrapError(err error, message string) error {
+	var scopeErr *client.MissingScopeError
+	if errors.As(err, &scopeErr) {
+		return status.Errorf(codes.PermissionDenied, "%s: %s", message, scopeErr.Error())
+	}
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	st := status.Newf(grpcCodeForStatus(apiErr.StatusCode), "%s: %s", message, apiErr.Error())
+	if apiErr.RateLimit != nil {
+		if withDetails, detailsErr := st.WithDetails(apiErr.RateLimit); detailsErr == nil {
+			st = withDetails
+		}
+	}
+
+	return st.Err()
+}
+
+// isNotFound reports whether err is the Accounts API answering 404.
+func isNotFound(err error) bool {
+	var apiErr *client.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isForbidden reports whether err is the Accounts API answering 403, e.g. for an organization the service account
+// has no access to.
+func isForbidden(err error) bool {
+	var apiErr *client.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden
+}
+
+// rateLimitAnnotations returns annotations carrying the rate limit data of the last API response, if any.
+func rateLimitAnnotations(rateLimit *v2.RateLimitDescription) annotations.Annotations {
+	annos := annotations.Annotations{}
+	if rateLimit != nil {
+		annos.WithRateLimiting(rateLimit)
+	}
+
+	return annos
+}
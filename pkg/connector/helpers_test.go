@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		code       codes.Code
+	}{
+		{statusCode: http.StatusBadRequest, code: codes.InvalidArgument},
+		{statusCode: http.StatusForbidden, code: codes.PermissionDenied},
+		{statusCode: http.StatusNotFound, code: codes.NotFound},
+		{statusCode: http.StatusConflict, code: codes.AlreadyExists},
+		{statusCode: http.StatusTooManyRequests, code: codes.Unavailable},
+		{statusCode: http.StatusBadGateway, code: codes.Unavailable},
+		{statusCode: http.StatusTeapot, code: codes.Unknown},
+	}
+
+	for _, test := range tests {
+		err := wrapError(&client.APIError{StatusCode: test.statusCode}, "baton-vgs: failed")
+		assert.Equal(t, test.code, status.Code(err), http.StatusText(test.statusCode))
+	}
+
+	err := wrapError(&client.MissingScopeError{Scope: client.ScopeOrganizationUsersWrite}, "baton-vgs: failed")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.ErrorContains(t, err, "organization-users:write scope not found")
+
+	plain := errors.New("boom")
+	err = wrapError(plain, "baton-vgs: failed")
+	assert.ErrorIs(t, err, plain)
+	assert.Equal(t, "baton-vgs: failed: boom", err.Error())
+}
+
+func TestWrapErrorAttachesRateLimit(t *testing.T) {
+	rateLimit := &v2.RateLimitDescription{
+		Status:  v2.RateLimitDescription_STATUS_OVERLIMIT,
+		Limit:   1,
+		ResetAt: timestamppb.New(time.Now().Add(30 * time.Second)),
+	}
+	err := wrapError(&client.APIError{StatusCode: http.StatusTooManyRequests, RateLimit: rateLimit}, "baton-vgs: failed")
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	assert.Len(t, st.Details(), 1)
+	detail, ok := st.Details()[0].(*v2.RateLimitDescription)
+	assert.True(t, ok)
+	assert.Equal(t, v2.RateLimitDescription_STATUS_OVERLIMIT, detail.Status)
+	assert.Equal(t, rateLimit.ResetAt.AsTime(), detail.ResetAt.AsTime())
+}
+
+func TestNewAccountRequest(t *testing.T) {
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"role":        "ADMIN",
+		"vault_roles": "tntlive:admin, tntsandbox:Write",
+	})
+	assert.Nil(t, err)
+
+	req, err := newAccountRequest(&v2.AccountInfo{
+		Emails:  []*v2.AccountInfo_Email{{Address: "other@example.com"}, {Address: "dave@example.com", IsPrimary: true}},
+		Profile: profile,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "dave@example.com", req.email)
+	assert.Equal(t, orgRoleAdmin, req.role)
+	assert.Equal(t, []client.InviteVault{{Id: "tntlive", Role: "admin"}, {Id: "tntsandbox", Role: "write"}}, req.vaults)
+
+	req, err = newAccountRequest(&v2.AccountInfo{Login: "frank@example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "frank@example.com", req.email)
+	assert.Equal(t, orgRoleMember, req.role)
+	assert.Empty(t, req.vaults)
+
+	_, err = newAccountRequest(&v2.AccountInfo{Login: "frank"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	profile, err = structpb.NewStruct(map[string]interface{}{"vault_roles": map[string]interface{}{"tntsandbox": "owner"}})
+	assert.Nil(t, err)
+	_, err = newAccountRequest(&v2.AccountInfo{Login: "frank@example.com", Profile: profile})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
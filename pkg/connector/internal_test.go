@@ -31,7 +31,7 @@ func TestUserResourceTypeList(t *testing.T) {
 		resourceType: &v2.ResourceType{},
 		client:       cli,
 	}
-	rs, _, _, err := user.List(ctx, &v2.ResourceId{}, &pagination.Token{})
+	rs, _, _, err := user.List(ctx, &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}, &pagination.Token{})
 	assert.Nil(t, err)
 	assert.NotNil(t, rs)
 }
@@ -65,7 +65,7 @@ func TestVaultResourceTypeList(t *testing.T) {
 		resourceType: &v2.ResourceType{},
 		client:       cli,
 	}
-	rs, _, _, err := vault.List(ctx, &v2.ResourceId{}, &pagination.Token{})
+	rs, _, _, err := vault.List(ctx, &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}, &pagination.Token{})
 	assert.Nil(t, err)
 	assert.NotNil(t, rs)
 }
@@ -73,7 +73,7 @@ func TestVaultResourceTypeList(t *testing.T) {
 func getClientForTesting(ctx context.Context) (*client.VGSClient, error) {
 	cfg := client.Config{}
 	cfg.WithVaultId(vaultId).
-		WithOrganizationId(orgId).
+		WithOrganizationIds(orgId).
 		WithServiceAccountClientId(clientId).
 		WithServiceAccountClientSecret(clientSecret)
 	cli, err := client.New(ctx, cfg)
@@ -102,7 +102,7 @@ func TestListVaults(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	lv, err := cliTest.ListVaults(ctx)
+	lv, _, _, err := cliTest.ListVaults(ctx, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, lv)
 }
@@ -115,7 +115,7 @@ func TestListVaultUsers(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	lvu, err := cliTest.ListVaultUsers(ctx, vaultId)
+	lvu, _, _, err := cliTest.ListVaultUsers(ctx, vaultId, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, lvu)
 }
@@ -128,12 +128,12 @@ func TestListUsers(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	lu, err := cliTest.ListUsers(ctx, orgId, vaultId)
+	lu, _, _, err := cliTest.ListUsers(ctx, orgId, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, lu)
 }
 
-func TestListUserInvites(t *testing.T) {
+func TestListInvites(t *testing.T) {
 	if clientId == "" && clientSecret == "" && orgId == "" && vaultId == "" {
 		t.Skip()
 	}
@@ -141,7 +141,7 @@ func TestListUserInvites(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	lui, err := cliTest.ListUserInvites(ctx, orgId)
+	lui, _, _, err := cliTest.ListInvites(ctx, orgId, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, lui)
 }
@@ -154,7 +154,7 @@ func TestListOrganizations(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	lo, err := cliTest.ListOrganizations(ctx)
+	lo, _, _, err := cliTest.ListOrganizations(ctx, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, lo)
 }
@@ -167,7 +167,7 @@ func TestUpdateVault(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	err = cliTest.UpdateUserAccessVault(ctx, vaultId, "ID9hRKLhcc6RWBvaHQ7L1Uan", "write")
+	_, err = cliTest.UpdateUserAccessVault(ctx, vaultId, "ID9hRKLhcc6RWBvaHQ7L1Uan", "write")
 	assert.Nil(t, err)
 }
 
@@ -179,6 +179,6 @@ func TestRevokeVault(t *testing.T) {
 	cliTest, err := getClientForTesting(ctx)
 	assert.Nil(t, err)
 
-	err = cliTest.RevokeUserAccessVault(ctx, vaultId, "IDjSP9BVbJ3RnPr2FonGxXp5")
+	_, err = cliTest.RevokeUserAccessVault(ctx, vaultId, "IDjSP9BVbJ3RnPr2FonGxXp5")
 	assert.Nil(t, err)
 }
@@ -0,0 +1,230 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	inviteStatusPending  = "PENDING"
+	inviteStatusAccepted = "ACCEPTED"
+)
+
+// defaultInviteStatuses are synced when no invite statuses are configured.
+var defaultInviteStatuses = []string{inviteStatusPending}
+
+type inviteResourceType struct {
+	resourceType *v2.ResourceType
+	client       *client.VGSClient
+	state        *syncState
+	statuses     []string
+}
+
+func (i *inviteResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return i.resourceType
+}
+
+// List returns the invitations to the parent organization whose status is one of the configured invite statuses.
+func (i *inviteResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
+	var rv []*v2.Resource
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeInvite.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	orgId := parentResourceID.Resource
+	invites, nextCursor, rateLimit, err := i.client.ListInvites(ctx, orgId, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if isForbidden(err) {
+		ctxzap.Extract(ctx).Warn("baton-vgs: skipping invites, the service account cannot read the organization invites",
+			zap.String("organization_id", orgId),
+			zap.Error(err),
+		)
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, wrapError(err, fmt.Sprintf("vgs-connector: failed to fetch invites of organization %s", orgId))
+	}
+
+	for _, invite := range invites {
+		if !slices.Contains(i.statuses, strings.ToUpper(invite.Status)) {
+			continue
+		}
+
+		ir, err := getInviteResource(invite, parentResourceID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, ir)
+	}
+
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+// Entitlements always returns an empty slice for invites.
+func (i *inviteResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for invites; invitees receive their roles once they accept.
+func (i *inviteResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Create is not supported; invites are sent by CreateAccount.
+func (i *inviteResourceType) Create(_ context.Context, _ *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	return nil, nil, status.Error(codes.Unimplemented, "baton-vgs: invites are sent with CreateAccount")
+}
+
+// Delete cancels a pending invite, so a user who never joined cannot accept it anymore. The invite is looked up
+// in every synced organization. Invites that no longer exist, or are no longer pending, are left as they are.
+func (i *inviteResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if resourceId.ResourceType != resourceTypeInvite.Id {
+		return nil, status.Errorf(codes.InvalidArgument, "baton-vgs: resource type %s is not an invite", resourceId.ResourceType)
+	}
+
+	orgId, invite, rateLimit, err := i.findInvite(ctx, resourceId.Resource)
+	if err != nil {
+		return rateLimitAnnotations(rateLimit), err
+	}
+	if invite == nil || !strings.EqualFold(invite.Status, inviteStatusPending) {
+		l.Info("baton-vgs: invite is not pending, nothing to cancel", zap.String("invite_id", resourceId.Resource))
+		return rateLimitAnnotations(rateLimit), nil
+	}
+
+	rateLimit, err = i.client.CancelInvite(ctx, orgId, invite.Id)
+	if err != nil && !isNotFound(err) {
+		return rateLimitAnnotations(rateLimit), wrapError(err, fmt.Sprintf("baton-vgs: failed to cancel invite %s", invite.Id))
+	}
+
+	l.Info("baton-vgs: cancelled invite",
+		zap.String("organization_id", orgId),
+		zap.String("invite_id", invite.Id),
+		zap.Bool("existed", err == nil),
+	)
+
+	return rateLimitAnnotations(rateLimit), nil
+}
+
+// findInvite pages through the invites of every synced organization looking for inviteId.
+func (i *inviteResourceType) findInvite(ctx context.Context, inviteId string) (string, *client.Invite, *v2.RateLimitDescription, error) {
+	orgIds, rateLimit, err := i.state.organizationIds(ctx)
+	if err != nil {
+		return "", nil, rateLimit, wrapError(err, "baton-vgs: failed to fetch organizations")
+	}
+
+	for _, orgId := range orgIds {
+		cursor := ""
+		for {
+			invites, next, rl, err := i.client.ListInvites(ctx, orgId, cursor)
+			rateLimit = rl
+			if err != nil {
+				return "", nil, rateLimit, wrapError(err, fmt.Sprintf("baton-vgs: failed to fetch invites of organization %s", orgId))
+			}
+
+			for _, invite := range invites {
+				if invite.Id == inviteId {
+					return orgId, &invite, rateLimit, nil
+				}
+			}
+
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	return "", nil, rateLimit, nil
+}
+
+// getInviteResource returns the invitee as a user resource. Only accepted invites belong to an enabled account.
+func getInviteResource(invite client.Invite, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	vaults := make([]interface{}, 0, len(invite.Vaults))
+	for _, v := range invite.Vaults {
+		vaults = append(vaults, map[string]interface{}{
+			"id":   v.Id,
+			"role": v.Role,
+		})
+	}
+
+	profile := map[string]interface{}{
+		"email":      invite.Email,
+		"invited_by": invite.InvitedBy,
+		"role":       invite.Role,
+		"status":     invite.Status,
+		"created_at": invite.CreatedAt,
+		"vaults":     vaults,
+	}
+
+	userStatus := v2.UserTrait_Status_STATUS_DISABLED
+	if strings.EqualFold(invite.Status, inviteStatusAccepted) {
+		userStatus = v2.UserTrait_Status_STATUS_ENABLED
+	}
+
+	userTraits := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithDetailedStatus(userStatus, strings.ToLower(invite.Status)),
+		rs.WithEmail(invite.Email, true),
+	}
+	if createdAt, err := time.Parse(time.RFC3339, invite.CreatedAt); err == nil {
+		userTraits = append(userTraits, rs.WithCreatedAt(createdAt))
+	}
+
+	return rs.NewUserResource(
+		invite.Email,
+		resourceTypeInvite,
+		invite.Id,
+		userTraits,
+		rs.WithParentResourceID(parentResourceID),
+	)
+}
+
+// normalizeInviteStatuses upper-cases the configured statuses, falling back to the defaults when none are set.
+func normalizeInviteStatuses(statuses []string) []string {
+	var rv []string
+	for _, s := range statuses {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && !slices.Contains(rv, s) {
+			rv = append(rv, s)
+		}
+	}
+
+	if len(rv) == 0 {
+		return defaultInviteStatuses
+	}
+
+	return rv
+}
+
+func inviteBuilder(c *client.VGSClient, state *syncState, statuses []string) *inviteResourceType {
+	return &inviteResourceType{
+		resourceType: resourceTypeInvite,
+		client:       c,
+		state:        state,
+		statuses:     normalizeInviteStatuses(statuses),
+	}
+}
@@ -3,18 +3,26 @@ package connector
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type orgResourceType struct {
 	resourceType *v2.ResourceType
 	client       *client.VGSClient
+	state        *syncState
 }
 
 const (
@@ -31,15 +39,31 @@ func (o *orgResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return o.resourceType
 }
 
-// List returns all the organizations from the database as resource objects.
+// List returns the synced organizations as resource objects: the configured ones, or every organization the service
+// account can see and read the members of.
 func (o *orgResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	var ret []*v2.Resource
-	orgs, err := o.client.ListOrganizations(ctx)
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeOrg.Id})
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("vgs-connector: failed to fetch org: %w", err)
+		return nil, "", nil, err
+	}
+
+	orgs, nextCursor, rateLimit, err := o.client.ListOrganizations(ctx, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch org")
+	}
+
+	orgIds, rateLimit, err := o.state.organizationIds(ctx)
+	if err != nil {
+		return nil, "", rateLimitAnnotations(rateLimit), wrapError(err, "vgs-connector: failed to fetch synced organizations")
 	}
 
 	for _, org := range orgs {
+		if !slices.Contains(orgIds, org.Id) {
+			continue
+		}
+
 		orgResource, err := rs.NewResource(
 			org.Name,
 			resourceTypeOrg,
@@ -49,6 +73,9 @@ func (o *orgResourceType) List(ctx context.Context, parentResourceID *v2.Resourc
 				&v2.ExternalLink{Url: org.Name},
 				&v2.V1Identifier{Id: fmt.Sprintf("org:%s", org.Id)},
 				&v2.ChildResourceType{ResourceTypeId: resourceTypeUser.Id},
+				&v2.ChildResourceType{ResourceTypeId: resourceTypeInvite.Id},
+				&v2.ChildResourceType{ResourceTypeId: resourceTypeServiceAccount.Id},
+				&v2.ChildResourceType{ResourceTypeId: resourceTypeVault.Id},
 			),
 		)
 
@@ -59,7 +86,12 @@ func (o *orgResourceType) List(ctx context.Context, parentResourceID *v2.Resourc
 		ret = append(ret, orgResource)
 	}
 
-	return ret, "", nil, nil
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return ret, nextPage, annos, nil
 }
 
 func (o *orgResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
@@ -78,21 +110,205 @@ func (o *orgResourceType) Entitlements(_ context.Context, resource *v2.Resource,
 	return rv, "", nil, nil
 }
 
+// Grants returns a grant on the member or admin entitlement for every member of the organization.
 func (o *orgResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	var rv []*v2.Grant
+	l := ctxzap.Extract(ctx)
+	b, err := ParsePageToken(pToken.Token, resource.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	users, nextCursor, rateLimit, err := o.client.ListUsers(ctx, resource.Id.Resource, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch organization members")
+	}
+
+	for _, usr := range users {
+		role := strings.ToLower(usr.Role)
+		if !slices.Contains(orgAccessLevels, role) {
+			l.Warn("baton-vgs: skipping organization member with unknown role",
+				zap.String("organization_id", resource.Id.Resource),
+				zap.String("user_id", usr.Id),
+				zap.String("role", usr.Role),
+			)
+			continue
+		}
+
+		usrCopy := usr
+		ur, err := getUserResource(&usrCopy, resource.Id)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("error creating user resource for organization %s: %w", resource.Id.Resource, err)
+		}
+
+		rv = append(rv, grant.NewGrant(resource, role, ur.Id))
+	}
+
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, annos, nil
 }
 
+// Grant changes the organization role of an existing member. Organization membership itself is only granted by
+// accepting an invitation, so granting a role to a user outside the organization fails.
 func (o *orgResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
-	return nil, nil
+	l := ctxzap.Extract(ctx)
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"baton-vgs: only users can be granted organization roles",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("baton-vgs: only users can be granted organization roles")
+	}
+
+	role, err := orgRoleFromEntitlement(entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	orgId := entitlement.Resource.Id.Resource
+	member, rateLimit, err := o.client.GetOrganizationMember(ctx, orgId, principal.Id.Resource)
+	if err != nil {
+		if isNotFound(err) {
+			return rateLimitAnnotations(rateLimit), status.Errorf(codes.FailedPrecondition,
+				"baton-vgs: user %s is not a member of organization %s; organization membership is granted by invitation",
+				principal.Id.Resource, orgId)
+		}
+		return rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to read organization member")
+	}
+
+	current := strings.ToLower(member.Role)
+	if !slices.Contains(orgAccessLevels, current) {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"baton-vgs: user %s has organization role %q, which cannot be changed to %s by the connector",
+			principal.Id.Resource, member.Role, role)
+	}
+
+	if current == role {
+		annos := rateLimitAnnotations(rateLimit)
+		annos.Update(&v2.GrantAlreadyExists{})
+		return annos, nil
+	}
+
+	rateLimit, err = o.client.UpdateUserRoleOrganization(ctx, orgId, principal.Id.Resource, role)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return annos, wrapError(err, "baton-vgs: failed to update organization role")
+	}
+
+	l.Info("baton-vgs: organization role changed",
+		zap.String("organization_id", orgId),
+		zap.String("user_id", principal.Id.Resource),
+		zap.String("from", current),
+		zap.String("to", role),
+	)
+
+	return annos, nil
 }
 
+// Revoke takes an organization role away. Revoking admin downgrades the user to member, revoking member revokes the
+// user's vault memberships in the organization and then removes them from it. Revoking a role the user does not
+// hold is a no-op.
 func (o *orgResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
-	return nil, nil
+	l := ctxzap.Extract(ctx)
+	principal := grant.Principal
+	entitlement := grant.Entitlement
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"baton-vgs: only users can be revoked organization roles",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("baton-vgs: only users can be revoked organization roles")
+	}
+
+	role, err := orgRoleFromEntitlement(entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	orgId := entitlement.Resource.Id.Resource
+	member, rateLimit, err := o.client.GetOrganizationMember(ctx, orgId, principal.Id.Resource)
+	if err != nil {
+		if isNotFound(err) {
+			annos := rateLimitAnnotations(rateLimit)
+			annos.Update(&v2.GrantAlreadyRevoked{})
+			return annos, nil
+		}
+		return rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to read organization member")
+	}
+
+	current := strings.ToLower(member.Role)
+	if current != role {
+		if !slices.Contains(orgAccessLevels, current) {
+			return nil, status.Errorf(codes.FailedPrecondition,
+				"baton-vgs: user %s has organization role %q, which cannot be revoked by the connector",
+				principal.Id.Resource, member.Role)
+		}
+		annos := rateLimitAnnotations(rateLimit)
+		annos.Update(&v2.GrantAlreadyRevoked{})
+		return annos, nil
+	}
+
+	if role == orgRoleAdmin {
+		rateLimit, err = o.client.UpdateUserRoleOrganization(ctx, orgId, principal.Id.Resource, orgRoleMember)
+		annos := rateLimitAnnotations(rateLimit)
+		if err != nil {
+			return annos, wrapError(err, "baton-vgs: failed to downgrade organization admin")
+		}
+
+		l.Info("baton-vgs: organization admin downgraded to member",
+			zap.String("organization_id", orgId),
+			zap.String("user_id", principal.Id.Resource),
+		)
+		return annos, nil
+	}
+
+	report := &offboardReport{organizationId: orgId, userId: principal.Id.Resource, email: member.Email}
+	rateLimit, err = revokeVaultMemberships(ctx, o.client, orgId, principal.Id.Resource, report)
+	if err != nil {
+		return rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to revoke vault memberships")
+	}
+
+	rateLimit, err = o.client.RemoveUserOrganization(ctx, orgId, principal.Id.Resource)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return annos, wrapError(err, "baton-vgs: failed to remove organization member")
+	}
+
+	l.Info("baton-vgs: user removed from organization",
+		zap.String("organization_id", orgId),
+		zap.String("user_id", principal.Id.Resource),
+		zap.Strings("vault_ids", report.vaultIds()),
+	)
+
+	return annos, nil
+}
+
+// orgRoleFromEntitlement returns the organization role an entitlement stands for.
+func orgRoleFromEntitlement(entitlement *v2.Entitlement) (string, error) {
+	_, parts, err := parseEntitlementID(entitlement.Id)
+	if err != nil {
+		return "", err
+	}
+
+	role := parts[len(parts)-1]
+	if !slices.Contains(orgAccessLevels, role) {
+		return "", status.Errorf(codes.InvalidArgument, "baton-vgs: unsupported organization role %q", role)
+	}
+
+	return role, nil
 }
 
-func orgBuilder(c *client.VGSClient) *orgResourceType {
+func orgBuilder(c *client.VGSClient, state *syncState) *orgResourceType {
 	return &orgResourceType{
 		resourceType: resourceTypeOrg,
 		client:       c,
+		state:        state,
 	}
 }
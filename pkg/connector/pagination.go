@@ -1,25 +0,0 @@
-package connector
-
-import (
-	"strconv"
-
-	"github.com/conductorone/baton-sdk/pkg/pagination"
-)
-
-func unmarshalSkipToken(token *pagination.Token) (int32, *pagination.Bag, error) {
-	b := &pagination.Bag{}
-	err := b.Unmarshal(token.Token)
-	if err != nil {
-		return 0, nil, err
-	}
-	current := b.Current()
-	skip := int32(0)
-	if current != nil && current.Token != "" {
-		skip64, err := strconv.ParseInt(current.Token, 10, 32)
-		if err != nil {
-			return 0, nil, err
-		}
-		skip = int32(skip64)
-	}
-	return skip, b, nil
-}
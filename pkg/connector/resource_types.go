@@ -11,6 +11,21 @@ var (
 		},
 		Annotations: annotationsForUserResourceType(),
 	}
+	resourceTypeInvite = &v2.ResourceType{
+		Id:          "invite",
+		DisplayName: "Invite",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+		Annotations: annotationsForUserResourceType(),
+	}
+	resourceTypeServiceAccount = &v2.ResourceType{
+		Id:          "service_account",
+		DisplayName: "Service Account",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
 	resourceTypeOrg = &v2.ResourceType{
 		Id:          "org",
 		DisplayName: "Org",
@@ -21,4 +36,11 @@ var (
 		DisplayName: "Vault",
 		Annotations: v1AnnotationsForResourceType("vault"),
 	}
+	resourceTypeVaultCredential = &v2.ResourceType{
+		Id:          "vault_credential",
+		DisplayName: "Vault Credential",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
 )
@@ -0,0 +1,271 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type serviceAccountResourceType struct {
+	resourceType          *v2.ResourceType
+	client                *client.VGSClient
+	state                 *syncState
+	revokePreviousSecrets bool
+	allowSelfRotation     bool
+}
+
+func (s *serviceAccountResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return s.resourceType
+}
+
+// List returns the service accounts of an organization. Service accounts are only listed under their organization,
+// and are skipped with a warning when the connector's own service account may not read them.
+func (s *serviceAccountResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
+	scopes, err := s.client.GetScopes(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "baton-vgs: failed to authenticate service account")
+	}
+	if !scopes.Has(client.ScopeServiceAccountsRead) {
+		ctxzap.Extract(ctx).Warn("baton-vgs: skipping service accounts, the connector is missing a scope",
+			zap.String("organization_id", parentResourceID.Resource),
+			zap.String("scope", client.ScopeServiceAccountsRead),
+		)
+		return nil, "", nil, nil
+	}
+
+	var rv []*v2.Resource
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeServiceAccount.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	serviceAccounts, nextCursor, rateLimit, err := s.client.ListServiceAccounts(ctx, parentResourceID.Resource, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch service accounts")
+	}
+
+	for _, sa := range serviceAccounts {
+		sr, err := getServiceAccountResource(sa, s.client.GetClientId(), parentResourceID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, sr)
+	}
+
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+// Entitlements returns an entitlement for every scope granted to the service account.
+func (s *serviceAccountResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	scopes, err := serviceAccountScopes(resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Entitlement, 0, len(scopes))
+	for _, scope := range scopes {
+		rv = append(rv, ent.NewPermissionEntitlement(resource, scope,
+			ent.WithDisplayName(fmt.Sprintf("%s Service Account %s", resource.DisplayName, scope)),
+			ent.WithDescription(fmt.Sprintf("%s scope of the %s service account in VGS", scope, resource.DisplayName)),
+			ent.WithGrantableTo(resourceTypeServiceAccount),
+		))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grants returns a grant of each scope entitlement to the service account holding it.
+func (s *serviceAccountResourceType) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	scopes, err := serviceAccountScopes(resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Grant, 0, len(scopes))
+	for _, scope := range scopes {
+		rv = append(rv, grant.NewGrant(resource, scope, resource.Id))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Rotate issues a new client secret for the service account and returns it to be encrypted for the requester.
+// The secret the connector itself authenticates with is only rotated when self rotation is allowed, since revoking
+// it would lock the connector out once its access token expires.
+func (s *serviceAccountResourceType) Rotate(ctx context.Context, resourceId *v2.ResourceId, _ *v2.CredentialOptions) ([]*v2.PlaintextData, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if resourceId.ResourceType != resourceTypeServiceAccount.Id {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "baton-vgs: cannot rotate credentials of resource type %s", resourceId.ResourceType)
+	}
+
+	clientId := resourceId.Resource
+	if clientId == s.client.GetClientId() {
+		if !s.allowSelfRotation {
+			return nil, nil, status.Errorf(codes.FailedPrecondition,
+				"baton-vgs: service account %s is the one the connector runs with; set %s to rotate its secret",
+				clientId, client.AllowSelfRotation)
+		}
+		l.Warn("baton-vgs: rotating the secret of the connector's own service account; update the connector configuration with the new secret",
+			zap.String("client_id", clientId),
+			zap.Bool("revoke_previous", s.revokePreviousSecrets),
+		)
+	}
+
+	orgId, rateLimit, err := s.serviceAccountOrganization(ctx, clientId)
+	if err != nil {
+		return nil, rateLimitAnnotations(rateLimit), err
+	}
+
+	secret, rateLimit, err := s.client.RotateServiceAccountSecret(ctx, orgId, clientId, s.revokePreviousSecrets)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, annos, wrapError(err, fmt.Sprintf("baton-vgs: failed to rotate the secret of service account %s", clientId))
+	}
+
+	l.Info("baton-vgs: rotated service account secret",
+		zap.String("organization_id", orgId),
+		zap.String("client_id", clientId),
+		zap.Bool("revoke_previous", s.revokePreviousSecrets),
+	)
+
+	return []*v2.PlaintextData{
+		{
+			Name:        "client_secret",
+			Description: fmt.Sprintf("Client secret of the VGS service account %s", clientId),
+			Bytes:       []byte(secret.ClientSecret),
+		},
+	}, annos, nil
+}
+
+// serviceAccountOrganization returns the synced organization the service account belongs to. With a single synced
+// organization it is used as is, otherwise the service accounts of each organization are searched.
+func (s *serviceAccountResourceType) serviceAccountOrganization(ctx context.Context, clientId string) (string, *v2.RateLimitDescription, error) {
+	orgIds, rateLimit, err := s.state.organizationIds(ctx)
+	if err != nil {
+		return "", rateLimit, wrapError(err, "baton-vgs: failed to fetch organizations")
+	}
+	if len(orgIds) == 1 {
+		return orgIds[0], rateLimit, nil
+	}
+
+	for _, orgId := range orgIds {
+		cursor := ""
+		for {
+			serviceAccounts, next, rl, err := s.client.ListServiceAccounts(ctx, orgId, cursor)
+			rateLimit = rl
+			if err != nil {
+				return "", rateLimit, wrapError(err, fmt.Sprintf("baton-vgs: failed to fetch service accounts of organization %s", orgId))
+			}
+
+			for _, sa := range serviceAccounts {
+				if sa.ClientId == clientId {
+					return orgId, rateLimit, nil
+				}
+			}
+
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	return "", rateLimit, status.Errorf(codes.NotFound, "baton-vgs: service account %s was not found in any synced organization", clientId)
+}
+
+// getServiceAccountResource returns the service account as a user resource of the service account type.
+func getServiceAccountResource(sa client.ServiceAccount, connectorClientId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	scopes := make([]interface{}, 0, len(sa.Scopes))
+	for _, scope := range sa.Scopes {
+		scopes = append(scopes, scope)
+	}
+
+	vaults := make([]interface{}, 0, len(sa.Vaults))
+	for _, vault := range sa.Vaults {
+		vaults = append(vaults, vault)
+	}
+
+	profile := map[string]interface{}{
+		"client_id":         sa.ClientId,
+		"name":              sa.Name,
+		"created_by":        sa.CreatedBy,
+		"created_at":        sa.CreatedAt,
+		"scopes":            scopes,
+		"vaults":            vaults,
+		"used_by_connector": sa.ClientId == connectorClientId,
+	}
+
+	userTraits := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+		rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		rs.WithUserLogin(sa.ClientId),
+	}
+	if createdAt, err := time.Parse(time.RFC3339, sa.CreatedAt); err == nil {
+		userTraits = append(userTraits, rs.WithCreatedAt(createdAt))
+	}
+
+	displayName := sa.Name
+	if displayName == "" {
+		displayName = sa.ClientId
+	}
+
+	return rs.NewUserResource(
+		displayName,
+		resourceTypeServiceAccount,
+		sa.ClientId,
+		userTraits,
+		rs.WithParentResourceID(parentResourceID),
+	)
+}
+
+// serviceAccountScopes reads the scopes stored in the profile of a service account resource.
+func serviceAccountScopes(resource *v2.Resource) ([]string, error) {
+	trait, err := rs.GetUserTrait(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	if list, ok := trait.GetProfile().AsMap()["scopes"].([]interface{}); ok {
+		for _, scope := range list {
+			if name, ok := scope.(string); ok && name != "" {
+				scopes = append(scopes, name)
+			}
+		}
+	}
+
+	return scopes, nil
+}
+
+func serviceAccountBuilder(c *client.VGSClient, state *syncState, revokePreviousSecrets, allowSelfRotation bool) *serviceAccountResourceType {
+	return &serviceAccountResourceType{
+		resourceType:          resourceTypeServiceAccount,
+		client:                c,
+		state:                 state,
+		revokePreviousSecrets: revokePreviousSecrets,
+		allowSelfRotation:     allowSelfRotation,
+	}
+}
@@ -0,0 +1,222 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// syncState holds what the resource types work out once per sync rather than on every page: the synced
+// organizations, the members of each, and the users already listed under earlier organizations. Baton validates the
+// connector at the start of every sync and Validate resets it, so a sync never acts on what an earlier one read.
+// Provisioning between syncs uses the organizations of the last sync.
+type syncState struct {
+	client *client.VGSClient
+
+	mtx     sync.Mutex
+	orgIds  []string
+	members map[string]*organizationMembers
+	listed  map[string]map[string]bool
+}
+
+// organizationMembers is the membership of an organization, or the 403 the service account got reading it.
+type organizationMembers struct {
+	ids     map[string]bool
+	byEmail map[string]string
+	err     error
+}
+
+func newSyncState(c *client.VGSClient) *syncState {
+	return &syncState{
+		client:  c,
+		members: map[string]*organizationMembers{},
+		listed:  map[string]map[string]bool{},
+	}
+}
+
+// reset forgets everything worked out so far, so the next sync reads it from VGS again.
+func (s *syncState) reset() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.orgIds = nil
+	s.members = map[string]*organizationMembers{}
+	s.listed = map[string]map[string]bool{}
+}
+
+// organizationIds returns the organizations the connector syncs: the configured ones, or every organization the
+// service account can see and read the members of.
+func (s *syncState) organizationIds(ctx context.Context) ([]string, *v2.RateLimitDescription, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.organizationIdsLocked(ctx)
+}
+
+func (s *syncState) organizationIdsLocked(ctx context.Context) ([]string, *v2.RateLimitDescription, error) {
+	if s.orgIds != nil {
+		return s.orgIds, nil, nil
+	}
+
+	ids, rateLimit, err := listOrganizationIds(ctx, s.client)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	s.orgIds = append([]string{}, ids...)
+	return s.orgIds, rateLimit, nil
+}
+
+// organizationMembers returns the members of an organization. When the service account may not read them, the 403
+// is returned again on every call without asking VGS.
+func (s *syncState) organizationMembers(ctx context.Context, orgId string) (*organizationMembers, *v2.RateLimitDescription, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.organizationMembersLocked(ctx, orgId)
+}
+
+func (s *syncState) organizationMembersLocked(ctx context.Context, orgId string) (*organizationMembers, *v2.RateLimitDescription, error) {
+	if m, ok := s.members[orgId]; ok {
+		if m.err != nil {
+			return nil, nil, m.err
+		}
+		return m, nil, nil
+	}
+
+	var (
+		m = &organizationMembers{
+			ids:     map[string]bool{},
+			byEmail: map[string]string{},
+		}
+		rateLimit *v2.RateLimitDescription
+		cursor    string
+	)
+	for {
+		users, next, rl, err := s.client.ListUsers(ctx, orgId, cursor)
+		rateLimit = rl
+		if isForbidden(err) {
+			s.members[orgId] = &organizationMembers{err: err}
+			return nil, rateLimit, err
+		}
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for _, u := range users {
+			m.ids[u.Id] = true
+			m.byEmail[strings.ToLower(u.Email)] = u.Id
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	s.members[orgId] = m
+	return m, rateLimit, nil
+}
+
+// listedBefore returns the ids of the users listed under the synced organizations that come before orgId.
+// Organizations whose members cannot be read list no users.
+func (s *syncState) listedBefore(ctx context.Context, orgId string) (map[string]bool, *v2.RateLimitDescription, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if listed, ok := s.listed[orgId]; ok {
+		return listed, nil, nil
+	}
+
+	orgIds, rateLimit, err := s.organizationIdsLocked(ctx)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	listed := map[string]bool{}
+	for _, id := range orgIds {
+		if id == orgId {
+			break
+		}
+
+		m, rl, err := s.organizationMembersLocked(ctx, id)
+		if rl != nil {
+			rateLimit = rl
+		}
+		if isForbidden(err) {
+			continue
+		}
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for userId := range m.ids {
+			listed[userId] = true
+		}
+	}
+
+	s.listed[orgId] = listed
+	return listed, rateLimit, nil
+}
+
+// listOrganizationIds reads the organizations the connector syncs from VGS: the configured ones, or every
+// organization the service account can see and read the members of.
+func listOrganizationIds(ctx context.Context, c *client.VGSClient) ([]string, *v2.RateLimitDescription, error) {
+	if ids := c.GetOrganizationIds(); len(ids) > 0 {
+		return ids, nil, nil
+	}
+
+	var (
+		ids       []string
+		rateLimit *v2.RateLimitDescription
+		cursor    string
+	)
+	for {
+		orgs, next, rl, err := c.ListOrganizations(ctx, cursor)
+		rateLimit = rl
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for _, org := range orgs {
+			ok, rl, err := readsMembers(ctx, c, org.Id)
+			rateLimit = rl
+			if err != nil {
+				return nil, rateLimit, err
+			}
+			if ok {
+				ids = append(ids, org.Id)
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	return ids, rateLimit, nil
+}
+
+// readsMembers reports whether the service account can read the members of a discovered organization. Those it
+// cannot read are left out of the sync with a warning.
+func readsMembers(ctx context.Context, c *client.VGSClient, orgId string) (bool, *v2.RateLimitDescription, error) {
+	_, _, rateLimit, err := c.ListUsers(ctx, orgId, "")
+	if isForbidden(err) {
+		ctxzap.Extract(ctx).Warn("baton-vgs: skipping organization, the service account cannot read its members",
+			zap.String("organization_id", orgId),
+			zap.Error(err),
+		)
+		return false, rateLimit, nil
+	}
+	if err != nil {
+		return false, rateLimit, err
+	}
+
+	return true, rateLimit, nil
+}
@@ -3,100 +3,392 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
 	client       *client.VGSClient
+	state        *syncState
 }
 
 func (u *userResourceType) ResourceType(ctx context.Context) *v2.ResourceType {
 	return u.resourceType
 }
 
-// List returns all the users from the database as resource objects.
+// List returns the members of the parent organization as resource objects. A user who belongs to several synced
+// organizations is listed once, under the first of them.
 // Users include a UserTrait because they are the 'shape' of a standard user.
 func (u *userResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	var (
-		pageToken string
-		rv        []*v2.Resource
-	)
-	_, b, err := unmarshalSkipToken(pToken)
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
+	var rv []*v2.Resource
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	if b.Current() == nil {
-		// Push onto stack in reverse
-		b.Push(pagination.PageState{
-			ResourceTypeID: "invites",
-		})
-		b.Push(pagination.PageState{
-			ResourceTypeID: "users",
-		})
+	orgId := parentResourceID.Resource
+	users, nextCursor, rateLimit, err := u.client.ListUsers(ctx, orgId, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if isForbidden(err) {
+		ctxzap.Extract(ctx).Warn("baton-vgs: skipping users, the service account cannot read the organization members",
+			zap.String("organization_id", orgId),
+			zap.Error(err),
+		)
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, wrapError(err, fmt.Sprintf("vgs-connector: failed to fetch users of organization %s", orgId))
+	}
+
+	listed, rateLimit, err := u.state.listedBefore(ctx, orgId)
+	if err != nil {
+		return nil, "", rateLimitAnnotations(rateLimit), wrapError(err, "vgs-connector: failed to fetch users of earlier organizations")
 	}
 
-	switch b.Current().ResourceTypeID {
-	case "users":
-		users, err := u.client.ListUsers(ctx, u.client.GetOrganizationId(), u.client.GetVaultId())
+	for _, usr := range users {
+		if listed[usr.Id] {
+			continue
+		}
+		usrCopy := usr
+		ur, err := getUserResource(&usrCopy, parentResourceID)
 		if err != nil {
-			return nil, "", nil, fmt.Errorf("vgs-connector: failed to fetch users: %w", err)
+			return nil, "", nil, err
 		}
+		rv = append(rv, ur)
+	}
 
-		for _, usr := range users {
-			usrCopy := usr
-			ur, err := getUserResource(&usrCopy, parentResourceID)
-			if err != nil {
-				return nil, "", nil, err
+	pageToken, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+// Entitlements always returns an empty slice for users.
+func (u *userResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for users since they don't have any entitlements.
+func (u *userResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// CreateAccount invites the user to an organization with the org role and vault roles from the account profile.
+// The profile names the organization, unless a single organization is synced. The account only exists once the
+// invite is accepted, so the pending invite is returned as the principal. Users who are already members, or already
+// hold a pending invite, are returned as they are without sending a new invite.
+func (u *userResourceType) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	_ *v2.CredentialOptions,
+) (connectorbuilder.CreateAccountResponse, []*v2.PlaintextData, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	req, err := newAccountRequest(accountInfo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	orgId, rateLimit, err := u.accountOrganization(ctx, req)
+	if err != nil {
+		return nil, nil, rateLimitAnnotations(rateLimit), err
+	}
+	org := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}
+
+	member, rateLimit, err := u.findMemberByEmail(ctx, orgId, req.email)
+	if err != nil {
+		return nil, nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to fetch organization members")
+	}
+	if member != nil {
+		ur, err := getUserResource(member, org)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		l.Info("baton-vgs: account already exists", zap.String("organization_id", orgId), zap.String("user_id", member.Id))
+		return &v2.CreateAccountResponse_SuccessResult{Resource: ur}, nil, rateLimitAnnotations(rateLimit), nil
+	}
+
+	invite, rateLimit, err := u.findPendingInvite(ctx, orgId, req.email)
+	if err != nil {
+		return nil, nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to fetch invites")
+	}
+	if invite == nil {
+		invite, rateLimit, err = u.client.CreateInvite(ctx, orgId, req.email, req.role, req.vaults)
+		if err != nil {
+			return nil, nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to invite user")
+		}
+
+		l.Info("baton-vgs: invited user to organization",
+			zap.String("organization_id", orgId),
+			zap.String("invite_id", invite.Id),
+			zap.String("role", req.role),
+		)
+	}
+
+	ir, err := getInviteResource(*invite, org)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &v2.CreateAccountResponse_ActionRequiredResult{
+		Resource:              ir,
+		Message:               fmt.Sprintf("%s has been invited to the VGS organization and becomes a member once the invite is accepted", invite.Email),
+		IsCreateAccountResult: true,
+	}, nil, rateLimitAnnotations(rateLimit), nil
+}
+
+// Create is not supported; users join the organization through CreateAccount invitations.
+func (u *userResourceType) Create(_ context.Context, _ *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	return nil, nil, status.Error(codes.Unimplemented, "baton-vgs: users are created with CreateAccount")
+}
+
+// Delete offboards a user from every synced organization. In each one their vault memberships are revoked first,
+// then the organization membership is removed and pending invites sent to their email are cancelled. The returned
+// annotations carry an offboarding report per organization listing everything that was removed. Organizations the
+// user is not a member of report nothing removed.
+func (u *userResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	orgIds, rateLimit, err := u.state.organizationIds(ctx)
+	if err != nil {
+		return rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to fetch organizations")
+	}
+
+	var reports []*offboardReport
+	for _, orgId := range orgIds {
+		report, rl, err := u.offboard(ctx, orgId, resourceId.Resource)
+		rateLimit = rl
+		if err != nil {
+			return rateLimitAnnotations(rateLimit), err
+		}
+		reports = append(reports, report)
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	for _, report := range reports {
+		reportStruct, err := report.toStruct()
+		if err != nil {
+			return nil, err
+		}
+		annos.Append(reportStruct)
+	}
+
+	return annos, nil
+}
+
+// offboard removes userId from a single organization.
+func (u *userResourceType) offboard(ctx context.Context, orgId, userId string) (*offboardReport, *v2.RateLimitDescription, error) {
+	l := ctxzap.Extract(ctx)
+	report := &offboardReport{organizationId: orgId, userId: userId}
+
+	member, rateLimit, err := u.client.GetOrganizationMember(ctx, orgId, userId)
+	if err != nil && !isNotFound(err) {
+		return nil, rateLimit, wrapError(err, fmt.Sprintf("baton-vgs: failed to read member of organization %s", orgId))
+	}
+
+	if member != nil {
+		report.email = member.Email
+		rateLimit, err = revokeVaultMemberships(ctx, u.client, orgId, member.Id, report)
+		if err != nil {
+			l.Error("baton-vgs: offboarding stopped", report.fields()...)
+			return nil, rateLimit, wrapError(err, "baton-vgs: failed to revoke vault memberships")
+		}
+
+		rateLimit, err = u.client.RemoveUserOrganization(ctx, orgId, member.Id)
+		if err != nil && !isNotFound(err) {
+			l.Error("baton-vgs: offboarding stopped", report.fields()...)
+			return nil, rateLimit, wrapError(err, "baton-vgs: failed to remove organization member")
+		}
+		report.organizationMembershipRemoved = err == nil
+
+		rateLimit, err = u.cancelPendingInvites(ctx, orgId, member.Email, report)
+		if err != nil {
+			l.Error("baton-vgs: offboarding stopped", report.fields()...)
+			return nil, rateLimit, wrapError(err, "baton-vgs: failed to cancel invites")
+		}
+	}
+
+	l.Info("baton-vgs: offboarded user", report.fields()...)
+
+	return report, rateLimit, nil
+}
+
+// revokeVaultMemberships removes userId from every vault of the organization, recording each removal in report.
+// Members are removed from the vaults before the organization, rather than relying on VGS to drop their vault
+// access along with the membership.
+func revokeVaultMemberships(ctx context.Context, c *client.VGSClient, orgId, userId string, report *offboardReport) (*v2.RateLimitDescription, error) {
+	var (
+		vaults    []client.Vault
+		rateLimit *v2.RateLimitDescription
+		cursor    string
+	)
+	for {
+		page, next, rl, err := c.ListVaults(ctx, cursor)
+		rateLimit = rl
+		if err != nil {
+			return rateLimit, err
+		}
+
+		for _, vault := range page {
+			if vault.OrganizationId == orgId {
+				vaults = append(vaults, vault)
 			}
-			rv = append(rv, ur)
 		}
 
-		pageToken, err = b.NextToken("")
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for _, vault := range vaults {
+		role, rl, err := vaultMemberRole(ctx, c, vault.Id, userId)
+		rateLimit = rl
 		if err != nil {
-			return nil, "", nil, err
+			return rateLimit, err
+		}
+		if role == "" {
+			continue
+		}
+
+		rateLimit, err = c.RevokeUserAccessVault(ctx, vault.Id, userId)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return rateLimit, err
 		}
-	case "invites":
-		userInvites, err := u.client.ListUserInvites(ctx, u.client.GetOrganizationId())
+		report.vaultMemberships = append(report.vaultMemberships, client.InviteVault{Id: vault.Id, Role: role})
+	}
+
+	return rateLimit, nil
+}
+
+// cancelPendingInvites cancels every pending invite sent to email, recording each one in report.
+func (u *userResourceType) cancelPendingInvites(ctx context.Context, orgId, email string, report *offboardReport) (*v2.RateLimitDescription, error) {
+	var (
+		pending   []string
+		rateLimit *v2.RateLimitDescription
+		cursor    string
+	)
+	for {
+		invites, next, rl, err := u.client.ListInvites(ctx, orgId, cursor)
+		rateLimit = rl
 		if err != nil {
-			return nil, "", nil, fmt.Errorf("vgs-connector: failed to fetch invites: %w", err)
+			return rateLimit, err
 		}
 
-		for _, usr := range userInvites {
-			usrCopy := usr
-			ur, err := getUserResource(&usrCopy, parentResourceID)
-			if err != nil {
-				return nil, "", nil, err
+		for _, invite := range invites {
+			if strings.EqualFold(invite.Email, email) && strings.EqualFold(invite.Status, inviteStatusPending) {
+				pending = append(pending, invite.Id)
 			}
-			rv = append(rv, ur)
 		}
 
-		pageToken = ""
-	default:
-		return nil, "", nil, fmt.Errorf("baton-vgs: unknown page state: %s", b.Current().ResourceTypeID)
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
-	return rv, pageToken, nil, nil
+	for _, inviteId := range pending {
+		rl, err := u.client.CancelInvite(ctx, orgId, inviteId)
+		rateLimit = rl
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return rateLimit, err
+		}
+		report.invitesCancelled = append(report.invitesCancelled, inviteId)
+	}
+
+	return rateLimit, nil
 }
 
-// Entitlements always returns an empty slice for users.
-func (u *userResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+// accountOrganization returns the organization to invite the account to: the one named in the account profile, or
+// the only synced organization.
+func (u *userResourceType) accountOrganization(ctx context.Context, req *accountRequest) (string, *v2.RateLimitDescription, error) {
+	if req.organizationId != "" {
+		if !u.client.IncludesOrganization(req.organizationId) {
+			return "", nil, status.Errorf(codes.InvalidArgument, "baton-vgs: organization %s is not synced", req.organizationId)
+		}
+		return req.organizationId, nil, nil
+	}
+
+	orgIds, rateLimit, err := u.state.organizationIds(ctx)
+	if err != nil {
+		return "", rateLimit, wrapError(err, "baton-vgs: failed to fetch organizations")
+	}
+	if len(orgIds) != 1 {
+		return "", rateLimit, status.Errorf(codes.InvalidArgument,
+			"baton-vgs: %s is required in the account profile when %d organizations are synced", accountProfileOrganization, len(orgIds))
+	}
+
+	return orgIds[0], rateLimit, nil
 }
 
-// Grants always returns an empty slice for users since they don't have any entitlements.
-func (u *userResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+// findMemberByEmail pages through the organization members looking for email.
+func (u *userResourceType) findMemberByEmail(ctx context.Context, orgId, email string) (*client.OrganizationUser, *v2.RateLimitDescription, error) {
+	cursor := ""
+	for {
+		users, next, rateLimit, err := u.client.ListUsers(ctx, orgId, cursor)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for _, usr := range users {
+			if strings.EqualFold(usr.Email, email) {
+				return &usr, rateLimit, nil
+			}
+		}
+
+		if next == "" {
+			return nil, rateLimit, nil
+		}
+		cursor = next
+	}
+}
+
+// findPendingInvite pages through the organization invites looking for a pending one sent to email.
+func (u *userResourceType) findPendingInvite(ctx context.Context, orgId, email string) (*client.Invite, *v2.RateLimitDescription, error) {
+	cursor := ""
+	for {
+		invites, next, rateLimit, err := u.client.ListInvites(ctx, orgId, cursor)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for _, invite := range invites {
+			if strings.EqualFold(invite.Email, email) && strings.EqualFold(invite.Status, inviteStatusPending) {
+				return &invite, rateLimit, nil
+			}
+		}
+
+		if next == "" {
+			return nil, rateLimit, nil
+		}
+		cursor = next
+	}
 }
 
-func userBuilder(c *client.VGSClient) *userResourceType {
+func userBuilder(c *client.VGSClient, state *syncState) *userResourceType {
 	return &userResourceType{
 		resourceType: resourceTypeUser,
 		client:       c,
+		state:        state,
 	}
 }
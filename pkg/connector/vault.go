@@ -3,6 +3,9 @@ package connector
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -18,31 +21,61 @@ import (
 type vaultResourceType struct {
 	resourceType *v2.ResourceType
 	client       *client.VGSClient
+
+	// roles holds the roles published as entitlements for each vault, so Grants only emits grants on them.
+	mtx   sync.Mutex
+	roles map[string][]string
 }
 
 const (
+	vaultRoleRead  = "read"
 	vaultRoleWrite = "write"
 	vaultRoleAdmin = "admin"
 )
 
+// vaultAccessLevels are the vault roles VGS supports. Every vault publishes them, along with any other role its
+// members are seen holding.
 var vaultAccessLevels = []string{
+	vaultRoleRead,
 	vaultRoleWrite,
 	vaultRoleAdmin,
 }
 
+// vaultRoleDowngrades maps a vault role to the role its holder keeps when it is revoked. Revoking read, the lowest
+// role, or a role outside the ladder removes the vault membership.
+var vaultRoleDowngrades = map[string]string{
+	vaultRoleAdmin: vaultRoleWrite,
+	vaultRoleWrite: vaultRoleRead,
+}
+
 func (v *vaultResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return v.resourceType
 }
 
-// List returns all the vaults from the database as resource objects.
+// List returns the vaults of the parent organization as resource objects.
 func (v *vaultResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
 	var ret []*v2.Resource
-	vaults, err := v.client.ListVaults(ctx)
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeVault.Id})
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("vgs-connector: failed to fetch vault: %w", err)
+		return nil, "", nil, err
+	}
+
+	vaults, nextCursor, rateLimit, err := v.client.ListVaults(ctx, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch vault")
 	}
 
 	for _, vault := range vaults {
+		// The vault list spans every organization the service account can see.
+		if vault.OrganizationId != parentResourceID.Resource {
+			continue
+		}
+
 		vaultResource, err := rs.NewResource(
 			vault.Name,
 			resourceTypeVault,
@@ -51,7 +84,7 @@ func (v *vaultResourceType) List(ctx context.Context, parentResourceID *v2.Resou
 			rs.WithAnnotation(
 				&v2.ExternalLink{Url: vault.Name},
 				&v2.V1Identifier{Id: fmt.Sprintf("vault:%s", vault.Id)},
-				&v2.ChildResourceType{ResourceTypeId: resourceTypeVault.Id},
+				&v2.ChildResourceType{ResourceTypeId: resourceTypeVaultCredential.Id},
 			),
 		)
 
@@ -62,56 +95,152 @@ func (v *vaultResourceType) List(ctx context.Context, parentResourceID *v2.Resou
 		ret = append(ret, vaultResource)
 	}
 
-	return ret, "", nil, nil
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return ret, nextPage, annos, nil
 }
 
-func (v *vaultResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	rv := make([]*v2.Entitlement, 0, len(vaultAccessLevels))
-	for _, level := range vaultAccessLevels {
+// Entitlements returns an entitlement for every supported vault role, plus one for every other role held by a
+// member of the vault.
+func (v *vaultResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	roles, rateLimit, err := v.discoverRoles(ctx, resource.Id.Resource)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch vault members")
+	}
+
+	rv := make([]*v2.Entitlement, 0, len(roles))
+	for _, level := range roles {
 		rv = append(rv, ent.NewPermissionEntitlement(resource, level,
 			ent.WithDisplayName(fmt.Sprintf("%s Vault %s", resource.DisplayName, titleCase(level))),
 			ent.WithDescription(fmt.Sprintf("Access to %s vault in VGS", resource.DisplayName)),
 			ent.WithAnnotation(&v2.V1Identifier{
 				Id: fmt.Sprintf("vault:%s:role:%s", resource.Id.Resource, level),
 			}),
-			ent.WithGrantableTo(resourceTypeVault),
+			ent.WithGrantableTo(resourceTypeUser),
 		))
 	}
 
-	return rv, "", nil, nil
+	return rv, "", annos, nil
 }
 
+// Grants returns a grant for every member of the vault. Members holding a role that was not published as an
+// entitlement are skipped with a warning.
 func (v *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
 	var (
 		err error
 		rv  []*v2.Grant
 	)
-	users, err := v.client.ListVaultUsers(ctx, resource.Id.Resource)
+	l := ctxzap.Extract(ctx)
+	b, err := ParsePageToken(pToken.Token, resource.Id)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
+	roles, rateLimit, err := v.publishedRoles(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", rateLimitAnnotations(rateLimit), wrapError(err, "vgs-connector: failed to fetch vault members")
+	}
+
+	users, nextCursor, rateLimit, err := v.client.ListVaultUsers(ctx, resource.Id.Resource, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch vault members")
+	}
+
 	for _, usr := range users {
+		role := strings.ToLower(usr.Attributes.Role)
+		if !slices.Contains(roles, role) {
+			l.Warn("baton-vgs: skipping vault member with unknown role",
+				zap.String("vault_id", resource.Id.Resource),
+				zap.String("user_id", usr.Attributes.Id),
+				zap.String("role", usr.Attributes.Role),
+			)
+			continue
+		}
+
 		userCopy := &client.OrganizationUser{
 			Id:    usr.Attributes.Id,
 			Name:  usr.Attributes.Email,
 			Type:  "users",
 			Email: usr.Attributes.Email,
 		}
-		ur, err := getUserResource(userCopy, resource.Id)
+		ur, err := getUserResource(userCopy, resource.ParentResourceId)
 		if err != nil {
 			return nil, "", nil, fmt.Errorf("error creating user resource for role %s: %w", resource.Id.Resource, err)
 		}
 
-		gr := grant.NewGrant(resource, usr.Attributes.Role, ur.Id)
+		gr := grant.NewGrant(resource, role, ur.Id)
 		rv = append(rv, gr)
 	}
 
-	return rv, "", nil, nil
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+// discoverRoles returns the supported vault roles followed by any other role held by a member of vaultId, and
+// remembers them as the roles published for the vault.
+func (v *vaultResourceType) discoverRoles(ctx context.Context, vaultId string) ([]string, *v2.RateLimitDescription, error) {
+	l := ctxzap.Extract(ctx)
+	roles := slices.Clone(vaultAccessLevels)
+	var extra []string
+	cursor := ""
+	for {
+		users, next, rateLimit, err := v.client.ListVaultUsers(ctx, vaultId, cursor)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		for _, usr := range users {
+			role := strings.ToLower(usr.Attributes.Role)
+			if role == "" || slices.Contains(roles, role) || slices.Contains(extra, role) {
+				continue
+			}
+
+			l.Warn("baton-vgs: found vault role outside the supported roles",
+				zap.String("vault_id", vaultId),
+				zap.String("role", usr.Attributes.Role),
+			)
+			extra = append(extra, role)
+		}
+
+		if next == "" {
+			slices.Sort(extra)
+			roles = append(roles, extra...)
+
+			v.mtx.Lock()
+			v.roles[vaultId] = roles
+			v.mtx.Unlock()
+
+			return roles, rateLimit, nil
+		}
+		cursor = next
+	}
+}
+
+// publishedRoles returns the roles Entitlements published for vaultId, discovering them again if this process
+// has not synced the vault's entitlements, e.g. when a sync is resumed.
+func (v *vaultResourceType) publishedRoles(ctx context.Context, vaultId string) ([]string, *v2.RateLimitDescription, error) {
+	v.mtx.Lock()
+	roles, ok := v.roles[vaultId]
+	v.mtx.Unlock()
+	if ok {
+		return roles, nil, nil
+	}
+
+	return v.discoverRoles(ctx, vaultId)
 }
 
-func (v *vaultResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
-	var role string
+// Grant gives an organization member a vault role. Users without a vault membership are added to the vault,
+// members holding another role have it replaced. The resulting grant is returned so it is recorded right away.
+func (v *vaultResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 	if principal.Id.ResourceType != resourceTypeUser.Id {
 		l.Warn(
@@ -119,31 +248,51 @@ func (v *vaultResourceType) Grant(ctx context.Context, principal *v2.Resource, e
 			zap.String("principal_type", principal.Id.ResourceType),
 			zap.String("principal_id", principal.Id.Resource),
 		)
-		return nil, fmt.Errorf("baton-vgs: only users can be granted role membership")
+		return nil, nil, fmt.Errorf("baton-vgs: only users can be granted role membership")
 	}
 
 	_, parts, err := parseEntitlementID(entitlement.Id)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	role = parts[len(parts)-1]
-	err = v.client.UpdateUserAccessVault(ctx,
-		entitlement.Resource.Id.Resource,
-		principal.Id.Resource,
-		role)
+	role := parts[len(parts)-1]
+	vaultId := entitlement.Resource.Id.Resource
+	grants := []*v2.Grant{grant.NewGrant(entitlement.Resource, role, principal.Id)}
+	current, rateLimit, err := vaultMemberRole(ctx, v.client, vaultId, principal.Id.Resource)
 	if err != nil {
-		return nil, err
+		return nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to read vault members")
 	}
 
-	l.Warn("Role Membership has been added.",
-		zap.String("vaultIdentifier", entitlement.Resource.Id.Resource),
-		zap.String("userId", principal.Id.Resource),
+	switch {
+	case strings.EqualFold(current, role):
+		annos := rateLimitAnnotations(rateLimit)
+		annos.Update(&v2.GrantAlreadyExists{})
+		return grants, annos, nil
+	case current == "":
+		rateLimit, err = v.client.AddUserAccessVault(ctx, vaultId, principal.Id.Resource, role)
+		if err != nil {
+			return nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to add vault member")
+		}
+	default:
+		rateLimit, err = v.client.UpdateUserAccessVault(ctx, vaultId, principal.Id.Resource, role)
+		if err != nil {
+			return nil, rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to update vault role membership")
+		}
+	}
+
+	l.Info("baton-vgs: vault role granted",
+		zap.String("vault_id", vaultId),
+		zap.String("user_id", principal.Id.Resource),
+		zap.String("from", current),
+		zap.String("to", role),
 	)
 
-	return nil, nil
+	return grants, rateLimitAnnotations(rateLimit), nil
 }
 
+// Revoke takes a vault role away. Revoking admin downgrades the member to write and revoking write downgrades them
+// to read; revoking any other role removes the vault membership. Revoking a role the user does not hold is a no-op.
 func (v *vaultResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 	principal := grant.Principal
@@ -157,30 +306,86 @@ func (v *vaultResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annota
 		return nil, fmt.Errorf("baton-vgs: only users can be revoked role membership")
 	}
 
-	_, _, err := parseEntitlementID(entitlement.Id)
+	_, parts, err := parseEntitlementID(entitlement.Id)
 	if err != nil {
 		return nil, err
 	}
 
-	err = v.client.RevokeUserAccessVault(ctx,
-		entitlement.Resource.Id.Resource,
-		principal.Id.Resource,
-	)
+	role := parts[len(parts)-1]
+	vaultId := entitlement.Resource.Id.Resource
+	current, rateLimit, err := vaultMemberRole(ctx, v.client, vaultId, principal.Id.Resource)
 	if err != nil {
-		return nil, err
+		return rateLimitAnnotations(rateLimit), wrapError(err, "baton-vgs: failed to read vault members")
+	}
+
+	if !strings.EqualFold(current, role) {
+		l.Info("baton-vgs: vault role not held, nothing to revoke",
+			zap.String("vault_id", vaultId),
+			zap.String("user_id", principal.Id.Resource),
+			zap.String("role", role),
+			zap.String("current_role", current),
+		)
+		annos := rateLimitAnnotations(rateLimit)
+		annos.Update(&v2.GrantAlreadyRevoked{})
+		return annos, nil
+	}
+
+	if downgrade, ok := vaultRoleDowngrades[role]; ok {
+		rateLimit, err = v.client.UpdateUserAccessVault(ctx, vaultId, principal.Id.Resource, downgrade)
+		annos := rateLimitAnnotations(rateLimit)
+		if err != nil {
+			return annos, wrapError(err, "baton-vgs: failed to downgrade vault role")
+		}
+
+		l.Info("baton-vgs: vault role downgraded",
+			zap.String("vault_id", vaultId),
+			zap.String("user_id", principal.Id.Resource),
+			zap.String("from", role),
+			zap.String("to", downgrade),
+		)
+		return annos, nil
+	}
+
+	rateLimit, err = v.client.RevokeUserAccessVault(ctx, vaultId, principal.Id.Resource)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return annos, wrapError(err, "baton-vgs: failed to remove vault role membership")
 	}
 
-	l.Warn("Role Membership has been removed.",
-		zap.String("vaultIdentifier", entitlement.Resource.Id.Resource),
-		zap.String("userId", principal.Id.Resource),
+	l.Info("baton-vgs: user removed from vault",
+		zap.String("vault_id", vaultId),
+		zap.String("user_id", principal.Id.Resource),
 	)
 
-	return nil, nil
+	return annos, nil
+}
+
+// vaultMemberRole returns the role of userId on vaultId, or an empty string if they are not a member.
+func vaultMemberRole(ctx context.Context, c *client.VGSClient, vaultId, userId string) (string, *v2.RateLimitDescription, error) {
+	cursor := ""
+	for {
+		members, next, rateLimit, err := c.ListVaultUsers(ctx, vaultId, cursor)
+		if err != nil {
+			return "", rateLimit, err
+		}
+
+		for _, m := range members {
+			if m.Attributes.Id == userId {
+				return m.Attributes.Role, rateLimit, nil
+			}
+		}
+
+		if next == "" {
+			return "", rateLimit, nil
+		}
+		cursor = next
+	}
 }
 
 func vaultBuilder(c *client.VGSClient) *vaultResourceType {
 	return &vaultResourceType{
 		resourceType: resourceTypeVault,
 		client:       c,
+		roles:        map[string][]string{},
 	}
 }
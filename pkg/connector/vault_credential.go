@@ -0,0 +1,319 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-vgs/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type vaultCredentialResourceType struct {
+	resourceType *v2.ResourceType
+	client       *client.VGSClient
+	state        *syncState
+	// revokeReplaced revokes the replaced credential once a rotation issued its replacement.
+	revokeReplaced bool
+}
+
+const (
+	vaultCredentialCreator = "creator"
+
+	// vaultCredentialIdSeparator joins the vault identifier and the credential id into the resource id, since
+	// credentials are only addressable through their vault.
+	vaultCredentialIdSeparator = "/"
+)
+
+func (v *vaultCredentialResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return v.resourceType
+}
+
+// List returns the access credentials of a vault, read from the vault management API the vault advertises.
+// Credentials are only listed under their vault, and are skipped with a warning when the connector's service
+// account may not read them or the vault advertises no vault management API.
+func (v *vaultCredentialResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeVault.Id {
+		return nil, "", nil, nil
+	}
+
+	l := ctxzap.Extract(ctx)
+	scopes, err := v.client.GetScopes(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "baton-vgs: failed to authenticate service account")
+	}
+	if !scopes.Has(client.ScopeCredentialsRead) {
+		l.Warn("baton-vgs: skipping vault credentials, the connector is missing a scope",
+			zap.String("vault_id", parentResourceID.Resource),
+			zap.String("scope", client.ScopeCredentialsRead),
+		)
+		return nil, "", nil, nil
+	}
+
+	vault, rateLimit, err := v.client.GetVault(ctx, parentResourceID.Resource)
+	if err != nil {
+		return nil, "", rateLimitAnnotations(rateLimit), wrapError(err, "vgs-connector: failed to fetch vault")
+	}
+	if vault.ManagementAPIURL == "" {
+		l.Warn("baton-vgs: skipping vault credentials, the vault does not advertise a vault management API",
+			zap.String("vault_id", vault.Id),
+		)
+		return nil, "", nil, nil
+	}
+
+	b, err := ParsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeVaultCredential.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	credentials, nextCursor, rateLimit, err := v.client.ListVaultCredentials(ctx, vault, b.Current().Token)
+	annos := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", annos, wrapError(err, "vgs-connector: failed to fetch vault credentials")
+	}
+
+	// Creators are resolved against the members read once per sync. Without access to them the credentials are
+	// still listed, without creators.
+	var creators map[string]string
+	members, rateLimit, err := v.state.organizationMembers(ctx, vault.OrganizationId)
+	if rateLimit != nil {
+		annos = rateLimitAnnotations(rateLimit)
+	}
+	switch {
+	case isForbidden(err):
+		l.Warn("baton-vgs: skipping vault credential creators, the service account cannot read the organization members",
+			zap.String("organization_id", vault.OrganizationId),
+			zap.Error(err),
+		)
+	case err != nil:
+		return nil, "", annos, wrapError(err, fmt.Sprintf("vgs-connector: failed to fetch users of organization %s", vault.OrganizationId))
+	default:
+		creators = members.byEmail
+	}
+
+	rv := make([]*v2.Resource, 0, len(credentials))
+	for _, credential := range credentials {
+		cr, err := getVaultCredentialResource(credential, creators[strings.ToLower(credential.CreatedBy)], parentResourceID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, cr)
+	}
+
+	nextPage, err := b.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+// Entitlements returns the creator entitlement of the credential.
+func (v *vaultCredentialResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return []*v2.Entitlement{
+		ent.NewAssignmentEntitlement(resource, vaultCredentialCreator,
+			ent.WithDisplayName(fmt.Sprintf("%s Vault Credential %s", resource.DisplayName, titleCase(vaultCredentialCreator))),
+			ent.WithDescription(fmt.Sprintf("Created the %s vault credential in VGS", resource.DisplayName)),
+			ent.WithGrantableTo(resourceTypeUser),
+		),
+	}, "", nil, nil
+}
+
+// Grants returns a grant of the creator entitlement to the member who created the credential. Credentials created
+// by someone who is no longer a member of the organization have no grant.
+func (v *vaultCredentialResourceType) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	trait, err := rs.GetUserTrait(resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	creatorId, _ := trait.GetProfile().AsMap()["created_by_user_id"].(string)
+	if creatorId == "" {
+		return nil, "", nil, nil
+	}
+
+	return []*v2.Grant{
+		grant.NewGrant(resource, vaultCredentialCreator, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: creatorId}),
+	}, "", nil, nil
+}
+
+// Rotate replaces a vault credential: a new credential is issued for the same vault and returned to be encrypted for
+// the requester. The replaced credential keeps working until it is deleted, unless revokeReplaced is set. Then the
+// returned annotations carry a report saying whether it was revoked: a replaced credential that cannot be revoked is
+// reported rather than failing the rotation, so the new secret is not lost.
+func (v *vaultCredentialResourceType) Rotate(ctx context.Context, resourceId *v2.ResourceId, _ *v2.CredentialOptions) ([]*v2.PlaintextData, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	vault, credentialId, rateLimit, err := v.vaultOfCredential(ctx, resourceId)
+	if err != nil {
+		return nil, rateLimitAnnotations(rateLimit), err
+	}
+
+	credential, rateLimit, err := v.client.CreateVaultCredential(ctx, vault)
+	if err != nil {
+		return nil, rateLimitAnnotations(rateLimit), wrapError(err, fmt.Sprintf("baton-vgs: failed to issue a credential for vault %s", vault.Id))
+	}
+
+	fields := []zap.Field{
+		zap.String("vault_id", vault.Id),
+		zap.String("credential_id", credentialId),
+		zap.String("replacement_credential_id", credential.Id),
+		zap.Bool("revoke_previous", v.revokeReplaced),
+	}
+	annos := annotations.Annotations{}
+	if v.revokeReplaced {
+		rateLimit, err = v.client.DeleteVaultCredential(ctx, vault, credentialId)
+		revoked := err == nil || isNotFound(err)
+		report := map[string]interface{}{
+			"vault_id":                    vault.Id,
+			"credential_id":               credentialId,
+			"replacement_credential_id":   credential.Id,
+			"replaced_credential_revoked": revoked,
+		}
+		if !revoked {
+			l.Error("baton-vgs: issued a replacement vault credential but failed to revoke the replaced one", append(fields, zap.Error(err))...)
+			report["revoke_error"] = err.Error()
+		}
+
+		reportStruct, err := structpb.NewStruct(report)
+		if err != nil {
+			return nil, rateLimitAnnotations(rateLimit), err
+		}
+		annos.Append(reportStruct)
+		if !revoked {
+			annos.Merge(rateLimitAnnotations(rateLimit)...)
+			return v.plaintexts(vault, credential), annos, nil
+		}
+	}
+
+	l.Info("baton-vgs: rotated vault credential", fields...)
+	annos.Merge(rateLimitAnnotations(rateLimit)...)
+
+	return v.plaintexts(vault, credential), annos, nil
+}
+
+// plaintexts returns the username and password of a newly issued vault credential.
+func (v *vaultCredentialResourceType) plaintexts(vault *client.Vault, credential *client.VaultCredential) []*v2.PlaintextData {
+	return []*v2.PlaintextData{
+		{
+			Name:        "username",
+			Description: fmt.Sprintf("Username of the access credential for vault %s", vault.Id),
+			Bytes:       []byte(credential.Id),
+		},
+		{
+			Name:        "password",
+			Description: fmt.Sprintf("Password of the access credential for vault %s", vault.Id),
+			Bytes:       []byte(credential.Secret),
+		},
+	}
+}
+
+func (v *vaultCredentialResourceType) Create(_ context.Context, _ *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	return nil, nil, status.Error(codes.Unimplemented, "baton-vgs: vault credentials are issued by rotating an existing one")
+}
+
+// Delete revokes a vault credential, e.g. one that leaked. Revoking a credential that no longer exists succeeds.
+func (v *vaultCredentialResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	vault, credentialId, rateLimit, err := v.vaultOfCredential(ctx, resourceId)
+	if err != nil {
+		return rateLimitAnnotations(rateLimit), err
+	}
+
+	rateLimit, err = v.client.DeleteVaultCredential(ctx, vault, credentialId)
+	if err != nil && !isNotFound(err) {
+		return rateLimitAnnotations(rateLimit), wrapError(err, fmt.Sprintf("baton-vgs: failed to revoke credential %s of vault %s", credentialId, vault.Id))
+	}
+
+	ctxzap.Extract(ctx).Info("baton-vgs: revoked vault credential",
+		zap.String("vault_id", vault.Id),
+		zap.String("credential_id", credentialId),
+		zap.Bool("existed", err == nil),
+	)
+
+	return rateLimitAnnotations(rateLimit), nil
+}
+
+// vaultOfCredential reads the vault a credential resource belongs to, along with the credential id.
+func (v *vaultCredentialResourceType) vaultOfCredential(ctx context.Context, resourceId *v2.ResourceId) (*client.Vault, string, *v2.RateLimitDescription, error) {
+	if resourceId.ResourceType != resourceTypeVaultCredential.Id {
+		return nil, "", nil, status.Errorf(codes.InvalidArgument, "baton-vgs: resource type %s is not a vault credential", resourceId.ResourceType)
+	}
+
+	vaultId, credentialId, err := parseVaultCredentialResourceId(resourceId.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	vault, rateLimit, err := v.client.GetVault(ctx, vaultId)
+	if err != nil {
+		return nil, "", rateLimit, wrapError(err, fmt.Sprintf("baton-vgs: failed to fetch vault %s", vaultId))
+	}
+
+	return vault, credentialId, rateLimit, nil
+}
+
+// getVaultCredentialResource returns the credential as a service account of the vault credential type.
+func getVaultCredentialResource(credential client.VaultCredential, creatorId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"credential_id": credential.Id,
+		"vault_id":      credential.VaultId,
+		"created_by":    credential.CreatedBy,
+		"created_at":    credential.CreatedAt,
+	}
+	if creatorId != "" {
+		profile["created_by_user_id"] = creatorId
+	}
+
+	userTraits := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+		rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		rs.WithUserLogin(credential.Id),
+	}
+	if createdAt, err := time.Parse(time.RFC3339, credential.CreatedAt); err == nil {
+		userTraits = append(userTraits, rs.WithCreatedAt(createdAt))
+	}
+
+	return rs.NewUserResource(
+		credential.Id,
+		resourceTypeVaultCredential,
+		vaultCredentialResourceId(credential.VaultId, credential.Id),
+		userTraits,
+		rs.WithParentResourceID(parentResourceID),
+	)
+}
+
+// vaultCredentialResourceId returns the resource id of a credential of a vault.
+func vaultCredentialResourceId(vaultId, credentialId string) string {
+	return vaultId + vaultCredentialIdSeparator + credentialId
+}
+
+// parseVaultCredentialResourceId splits the resource id of a vault credential into the vault identifier and the
+// credential id.
+func parseVaultCredentialResourceId(id string) (string, string, error) {
+	vaultId, credentialId, ok := strings.Cut(id, vaultCredentialIdSeparator)
+	if !ok || vaultId == "" || credentialId == "" {
+		return "", "", status.Errorf(codes.InvalidArgument, "baton-vgs: invalid vault credential id %q, expected vault/credential", id)
+	}
+
+	return vaultId, credentialId, nil
+}
+
+func vaultCredentialBuilder(c *client.VGSClient, state *syncState, revokeReplaced bool) *vaultCredentialResourceType {
+	return &vaultCredentialResourceType{
+		resourceType:   resourceTypeVaultCredential,
+		client:         c,
+		state:          state,
+		revokeReplaced: revokeReplaced,
+	}
+}
@@ -0,0 +1,137 @@
+package vgsfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Fixture is the state the fake Accounts API starts from. It can be built in Go or loaded from a JSON file.
+type Fixture struct {
+	Clients       []Client       `json:"clients,omitempty"`
+	Organizations []Organization `json:"organizations,omitempty"`
+	Members       []Member       `json:"members,omitempty"`
+	Invites       []Invite       `json:"invites,omitempty"`
+	Vaults        []Vault        `json:"vaults,omitempty"`
+	VaultMembers  []VaultMember  `json:"vault_members,omitempty"`
+
+	VaultCredentials []VaultCredential `json:"vault_credentials,omitempty"`
+	AuditLogs        []AuditLog        `json:"audit_logs,omitempty"`
+}
+
+// Client is a service account allowed to request tokens with the client credentials grant. Clients with an
+// organization id are listed as that organization's service accounts, and only reach that organization and the
+// other organizations they are given access to. Previous secrets keep working after a rotation until they are
+// revoked.
+type Client struct {
+	Id              string   `json:"id"`
+	Secret          string   `json:"secret"`
+	PreviousSecrets []string `json:"previous_secrets,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	OrganizationId  string   `json:"organization_id,omitempty"`
+	Organizations   []string `json:"organizations,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Vaults          []string `json:"vaults,omitempty"`
+	CreatedBy       string   `json:"created_by,omitempty"`
+	CreatedAt       string   `json:"created_at,omitempty"`
+}
+
+type Organization struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name,omitempty"`
+	State       string   `json:"state,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	CreatedAt   string   `json:"created_at,omitempty"`
+}
+
+// Member is a user belonging to an organization.
+type Member struct {
+	OrganizationId string `json:"organization_id"`
+	Id             string `json:"id"`
+	Name           string `json:"name,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Role           string `json:"role,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+}
+
+// Invite is a pending, accepted or expired invitation to an organization.
+type Invite struct {
+	OrganizationId string        `json:"organization_id"`
+	Id             string        `json:"id"`
+	Email          string        `json:"email,omitempty"`
+	InvitedBy      string        `json:"invited_by,omitempty"`
+	Status         string        `json:"status,omitempty"`
+	Role           string        `json:"role,omitempty"`
+	Vaults         []InviteVault `json:"vaults,omitempty"`
+	CreatedAt      string        `json:"created_at,omitempty"`
+}
+
+// InviteVault is a vault role an invitee receives once the invite is accepted.
+type InviteVault struct {
+	Id   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// Vault is identified by its vault identifier, e.g. tntabc123.
+type Vault struct {
+	OrganizationId string `json:"organization_id"`
+	Id             string `json:"id"`
+	Name           string `json:"name,omitempty"`
+	Environment    string `json:"environment,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+}
+
+// VaultMember grants an organization member a role on a vault.
+type VaultMember struct {
+	VaultId string `json:"vault_id"`
+	UserId  string `json:"user_id"`
+	Role    string `json:"role"`
+}
+
+// VaultCredential is an access credential for the HTTP API of a vault, served by the vault management API.
+type VaultCredential struct {
+	VaultId   string `json:"vault_id"`
+	Id        string `json:"id"`
+	Secret    string `json:"secret,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// AuditLog is an entry of an organization's audit log. The user, vault and role describe the subject of the
+// action, when it has one.
+type AuditLog struct {
+	OrganizationId string `json:"organization_id"`
+	Id             string `json:"id"`
+	Action         string `json:"action"`
+	OccurredAt     string `json:"occurred_at"`
+	ActorId        string `json:"actor_id,omitempty"`
+	ActorEmail     string `json:"actor_email,omitempty"`
+	UserId         string `json:"user_id,omitempty"`
+	UserEmail      string `json:"user_email,omitempty"`
+	VaultId        string `json:"vault_id,omitempty"`
+	Role           string `json:"role,omitempty"`
+	InviteId       string `json:"invite_id,omitempty"`
+}
+
+// LoadFixture reads a fixture from a JSON file.
+func LoadFixture(path string) (Fixture, error) {
+	var f Fixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("vgsfake: invalid fixture %s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// clone returns a deep copy so seeding a server never aliases the caller's slices.
+func (f Fixture) clone() Fixture {
+	data, _ := json.Marshal(f)
+	var c Fixture
+	_ = json.Unmarshal(data, &c)
+	return c
+}
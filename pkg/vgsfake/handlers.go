@@ -0,0 +1,704 @@
+package vgsfake
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+func organizationObject(o Organization) resourceObject {
+	return resourceObject{
+		Id:   o.Id,
+		Type: "organizations",
+		Attributes: map[string]any{
+			"name":        o.Name,
+			"state":       o.State,
+			"permissions": o.Permissions,
+			"created_at":  o.CreatedAt,
+		},
+	}
+}
+
+func memberObject(m Member) resourceObject {
+	return resourceObject{
+		Id:   m.Id,
+		Type: "users",
+		Attributes: map[string]any{
+			"id":            m.Id,
+			"name":          m.Name,
+			"email_address": m.Email,
+			"role":          m.Role,
+			"created_at":    m.CreatedAt,
+		},
+	}
+}
+
+func inviteObject(i Invite) resourceObject {
+	vaults := make([]map[string]string, 0, len(i.Vaults))
+	for _, v := range i.Vaults {
+		vaults = append(vaults, map[string]string{"identifier": v.Id, "role": v.Role})
+	}
+
+	return resourceObject{
+		Id:   i.Id,
+		Type: "invites",
+		Attributes: map[string]any{
+			"invite_id":     i.Id,
+			"invite_status": i.Status,
+			"user_email":    i.Email,
+			"invited_by":    i.InvitedBy,
+			"role":          i.Role,
+			"vaults":        vaults,
+			"created_at":    i.CreatedAt,
+		},
+	}
+}
+
+func serviceAccountObject(c Client) resourceObject {
+	scopes := make([]map[string]string, 0, len(c.Scopes))
+	for _, scope := range c.Scopes {
+		scopes = append(scopes, map[string]string{"name": scope})
+	}
+
+	return resourceObject{
+		Id:   c.Id,
+		Type: "service_accounts",
+		Attributes: map[string]any{
+			"client_id":  c.Id,
+			"name":       c.Name,
+			"scopes":     scopes,
+			"vaults":     c.Vaults,
+			"created_by": c.CreatedBy,
+			"created_at": c.CreatedAt,
+		},
+	}
+}
+
+func (s *Server) vaultObject(v Vault) resourceObject {
+	return resourceObject{
+		Id:   v.Id,
+		Type: "vaults",
+		Attributes: map[string]any{
+			"identifier":  v.Id,
+			"name":        v.Name,
+			"environment": v.Environment,
+			"created_at":  v.CreatedAt,
+		},
+		Relationships: map[string]any{
+			"organization": map[string]any{
+				"data": map[string]string{"id": v.OrganizationId, "type": "organizations"},
+			},
+		},
+		Links: map[string]any{
+			"self":                 s.srv.URL + "/vaults/" + v.Id,
+			"vault_management_api": s.VaultManagementURL(),
+		},
+	}
+}
+
+// vaultCredentialObject only includes the secret when asked to, since it is only returned when a credential is issued.
+func vaultCredentialObject(c VaultCredential, withSecret bool) resourceObject {
+	attributes := map[string]any{
+		"key":        c.Id,
+		"vault_id":   c.VaultId,
+		"created_by": c.CreatedBy,
+		"created_at": c.CreatedAt,
+	}
+	if withSecret {
+		attributes["secret"] = c.Secret
+	}
+
+	return resourceObject{
+		Id:         c.Id,
+		Type:       "credentials",
+		Attributes: attributes,
+	}
+}
+
+func auditLogObject(e AuditLog) resourceObject {
+	return resourceObject{
+		Id:   e.Id,
+		Type: "audit_logs",
+		Attributes: map[string]any{
+			"action":      e.Action,
+			"occurred_at": e.OccurredAt,
+			"actor_id":    e.ActorId,
+			"actor_email": e.ActorEmail,
+			"user_id":     e.UserId,
+			"user_email":  e.UserEmail,
+			"vault_id":    e.VaultId,
+			"role":        e.Role,
+			"invite_id":   e.InviteId,
+		},
+	}
+}
+
+func (s *Server) vaultMemberObject(m VaultMember) resourceObject {
+	email := ""
+	for _, member := range s.state.Members {
+		if member.Id == m.UserId {
+			email = member.Email
+			break
+		}
+	}
+
+	return resourceObject{
+		Id:   m.UserId,
+		Type: "vault_members",
+		Attributes: map[string]any{
+			"id":    m.UserId,
+			"email": email,
+			"role":  m.Role,
+		},
+	}
+}
+
+func (s *Server) organizationExists(orgId string) bool {
+	for _, o := range s.state.Organizations {
+		if o.Id == orgId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) findVault(vaultId string) (Vault, bool) {
+	for _, v := range s.state.Vaults {
+		if v.Id == vaultId {
+			return v, true
+		}
+	}
+
+	return Vault{}, false
+}
+
+func (s *Server) isMember(orgId, userId string) bool {
+	return s.memberIndex(orgId, userId) >= 0
+}
+
+func (s *Server) memberIndex(orgId, userId string) int {
+	for i, m := range s.state.Members {
+		if m.OrganizationId == orgId && m.Id == userId {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Server) vaultMemberIndex(vaultId, userId string) int {
+	for i, m := range s.state.VaultMembers {
+		if m.VaultId == vaultId && m.UserId == userId {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Server) listOrganizations(w http.ResponseWriter, r *http.Request) {
+	c := s.requestClient(r)
+	s.mtx.Lock()
+	objects := make([]resourceObject, 0, len(s.state.Organizations))
+	for _, o := range s.state.Organizations {
+		if c.canAccess(o.Id) {
+			objects = append(objects, organizationObject(o))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+func (s *Server) getOrganization(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, o := range s.state.Organizations {
+		if o.Id == r.PathValue("org") {
+			writeDocument(w, http.StatusOK, organizationObject(o))
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "not_found", "organization not found")
+}
+
+func (s *Server) listMembers(w http.ResponseWriter, r *http.Request) {
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	if !s.organizationExists(orgId) {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "organization not found")
+		return
+	}
+
+	var objects []resourceObject
+	for _, m := range s.state.Members {
+		if m.OrganizationId == orgId {
+			objects = append(objects, memberObject(m))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+func (s *Server) getMember(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.memberIndex(r.PathValue("org"), r.PathValue("user"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "organization member not found")
+		return
+	}
+
+	writeDocument(w, http.StatusOK, memberObject(s.state.Members[i]))
+}
+
+type memberAttributes struct {
+	Role string `json:"role"`
+}
+
+func (s *Server) updateMember(w http.ResponseWriter, r *http.Request) {
+	var attrs memberAttributes
+	if err := decodeAttributes(r, &attrs); err != nil || (attrs.Role != "ADMIN" && attrs.Role != "MEMBER") {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_role", "role must be ADMIN or MEMBER")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.memberIndex(r.PathValue("org"), r.PathValue("user"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "organization member not found")
+		return
+	}
+
+	s.state.Members[i].Role = attrs.Role
+	writeDocument(w, http.StatusOK, memberObject(s.state.Members[i]))
+}
+
+// deleteMember removes a user from an organization along with their access to its vaults.
+func (s *Server) deleteMember(w http.ResponseWriter, r *http.Request) {
+	orgId, userId := r.PathValue("org"), r.PathValue("user")
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.memberIndex(orgId, userId)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "organization member not found")
+		return
+	}
+	// Vault memberships are left in place: VGS does not document removing them along with the member.
+	s.state.Members = append(s.state.Members[:i], s.state.Members[i+1:]...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listInvites(w http.ResponseWriter, r *http.Request) {
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	if !s.organizationExists(orgId) {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "organization not found")
+		return
+	}
+
+	var objects []resourceObject
+	for _, i := range s.state.Invites {
+		if i.OrganizationId == orgId {
+			objects = append(objects, inviteObject(i))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+// listAuditLogs returns the audit log of an organization oldest first. filter[since] keeps the entries that occurred
+// at or after an RFC 3339 time.
+func (s *Server) listAuditLogs(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if raw := r.URL.Query().Get("filter[since]"); raw != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_filter", "filter[since] must be an RFC 3339 time")
+			return
+		}
+	}
+
+	// Like VGS, entries come newest first unless sort asks otherwise.
+	ascending := false
+	switch r.URL.Query().Get("sort") {
+	case "occurred_at":
+		ascending = true
+	case "", "-occurred_at":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_sort", "sort must be occurred_at or -occurred_at")
+		return
+	}
+
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	if !s.organizationExists(orgId) {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "organization not found")
+		return
+	}
+
+	var entries []AuditLog
+	for _, e := range s.state.AuditLogs {
+		occurredAt, err := time.Parse(time.RFC3339, e.OccurredAt)
+		if e.OrganizationId == orgId && err == nil && !occurredAt.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	s.mtx.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, _ := time.Parse(time.RFC3339, entries[i].OccurredAt)
+		b, _ := time.Parse(time.RFC3339, entries[j].OccurredAt)
+		if ascending {
+			return a.Before(b)
+		}
+		return b.Before(a)
+	})
+
+	objects := make([]resourceObject, 0, len(entries))
+	for _, e := range entries {
+		objects = append(objects, auditLogObject(e))
+	}
+
+	s.writePage(w, r, objects)
+}
+
+type inviteAttributes struct {
+	UserEmail string `json:"user_email"`
+	Role      string `json:"role"`
+	Vaults    []struct {
+		Identifier string `json:"identifier"`
+		Role       string `json:"role"`
+	} `json:"vaults"`
+}
+
+// createInvite invites an email to an organization. Only one pending invite per email is allowed.
+func (s *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	var attrs inviteAttributes
+	if err := decodeAttributes(r, &attrs); err != nil || attrs.UserEmail == "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_invite", "user_email is required")
+		return
+	}
+	if attrs.Role != "ADMIN" && attrs.Role != "MEMBER" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_role", "role must be ADMIN or MEMBER")
+		return
+	}
+
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.organizationExists(orgId) {
+		writeError(w, http.StatusNotFound, "not_found", "organization not found")
+		return
+	}
+
+	for _, i := range s.state.Invites {
+		if i.OrganizationId == orgId && i.Status == "PENDING" && strings.EqualFold(i.Email, attrs.UserEmail) {
+			writeError(w, http.StatusConflict, "invite_exists", "a pending invite already exists for this email")
+			return
+		}
+	}
+
+	invite := Invite{
+		OrganizationId: orgId,
+		Id:             "INV" + randomHex(6),
+		Email:          attrs.UserEmail,
+		InvitedBy:      "service-account",
+		Status:         "PENDING",
+		Role:           attrs.Role,
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, v := range attrs.Vaults {
+		if vault, ok := s.findVault(v.Identifier); !ok || vault.OrganizationId != orgId {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_vault", fmt.Sprintf("vault %s does not belong to the organization", v.Identifier))
+			return
+		}
+		invite.Vaults = append(invite.Vaults, InviteVault{Id: v.Identifier, Role: v.Role})
+	}
+
+	s.state.Invites = append(s.state.Invites, invite)
+	writeDocument(w, http.StatusCreated, inviteObject(invite))
+}
+
+// deleteInvite cancels an invite. Accepted invites can no longer be cancelled.
+func (s *Server) deleteInvite(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, invite := range s.state.Invites {
+		if invite.OrganizationId != r.PathValue("org") || invite.Id != r.PathValue("invite") {
+			continue
+		}
+
+		if invite.Status == "ACCEPTED" {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_invite", "accepted invites cannot be cancelled")
+			return
+		}
+
+		s.state.Invites = append(s.state.Invites[:i], s.state.Invites[i+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "not_found", "invite not found")
+}
+
+func (s *Server) listServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	if !s.organizationExists(orgId) {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "organization not found")
+		return
+	}
+
+	var objects []resourceObject
+	for _, c := range s.state.Clients {
+		if c.OrganizationId == orgId {
+			objects = append(objects, serviceAccountObject(c))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+type serviceAccountSecretAttributes struct {
+	RevokePrevious bool `json:"revoke_previous"`
+}
+
+// rotateServiceAccountSecret issues a new secret for a service account of the organization. The current secret
+// keeps working unless revoke_previous is set, in which case every earlier secret stops working.
+func (s *Server) rotateServiceAccountSecret(w http.ResponseWriter, r *http.Request) {
+	var attrs serviceAccountSecretAttributes
+	if err := decodeAttributes(r, &attrs); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_secret", "invalid request body")
+		return
+	}
+
+	orgId := r.PathValue("org")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, c := range s.state.Clients {
+		if c.OrganizationId != orgId || c.Id != r.PathValue("client") {
+			continue
+		}
+
+		if attrs.RevokePrevious {
+			c.PreviousSecrets = nil
+		} else {
+			c.PreviousSecrets = append(c.PreviousSecrets, c.Secret)
+		}
+		c.Secret = randomHex(24)
+		s.state.Clients[i] = c
+
+		writeDocument(w, http.StatusCreated, resourceObject{
+			Id:   c.Id,
+			Type: "service_account_secrets",
+			Attributes: map[string]any{
+				"client_id":       c.Id,
+				"client_secret":   c.Secret,
+				"revoke_previous": attrs.RevokePrevious,
+				"created_at":      time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "not_found", "service account not found")
+}
+
+func (s *Server) listVaults(w http.ResponseWriter, r *http.Request) {
+	c := s.requestClient(r)
+	s.mtx.Lock()
+	objects := make([]resourceObject, 0, len(s.state.Vaults))
+	for _, v := range s.state.Vaults {
+		if c.canAccess(v.OrganizationId) {
+			objects = append(objects, s.vaultObject(v))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+func (s *Server) getVault(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	v, ok := s.findVault(r.PathValue("vault"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "vault not found")
+		return
+	}
+
+	writeDocument(w, http.StatusOK, s.vaultObject(v))
+}
+
+func (s *Server) listVaultMembers(w http.ResponseWriter, r *http.Request) {
+	vaultId := r.PathValue("vault")
+	s.mtx.Lock()
+	if _, ok := s.findVault(vaultId); !ok {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "vault not found")
+		return
+	}
+
+	var objects []resourceObject
+	for _, m := range s.state.VaultMembers {
+		if m.VaultId == vaultId {
+			objects = append(objects, s.vaultMemberObject(m))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+func (s *Server) listVaultCredentials(w http.ResponseWriter, r *http.Request) {
+	vaultId := r.PathValue("vault")
+	s.mtx.Lock()
+	if _, ok := s.findVault(vaultId); !ok {
+		s.mtx.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "vault not found")
+		return
+	}
+
+	var objects []resourceObject
+	for _, c := range s.state.VaultCredentials {
+		if c.VaultId == vaultId {
+			objects = append(objects, vaultCredentialObject(c, false))
+		}
+	}
+	s.mtx.Unlock()
+
+	s.writePage(w, r, objects)
+}
+
+// createVaultCredential issues a credential for a vault. Its secret is only included in this response.
+func (s *Server) createVaultCredential(w http.ResponseWriter, r *http.Request) {
+	vaultId := r.PathValue("vault")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.findVault(vaultId); !ok {
+		writeError(w, http.StatusNotFound, "not_found", "vault not found")
+		return
+	}
+
+	credential := VaultCredential{
+		VaultId:   vaultId,
+		Id:        "US" + randomHex(6),
+		Secret:    randomHex(16),
+		CreatedBy: "service-account",
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	s.state.VaultCredentials = append(s.state.VaultCredentials, credential)
+
+	writeDocument(w, http.StatusCreated, vaultCredentialObject(credential, true))
+}
+
+func (s *Server) deleteVaultCredential(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, c := range s.state.VaultCredentials {
+		if c.VaultId == r.PathValue("vault") && c.Id == r.PathValue("credential") {
+			s.state.VaultCredentials = append(s.state.VaultCredentials[:i], s.state.VaultCredentials[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "not_found", "credential not found")
+}
+
+type vaultMemberAttributes struct {
+	UserId string `json:"user_id,omitempty"`
+	Role   string `json:"role"`
+}
+
+func (s *Server) createVaultMember(w http.ResponseWriter, r *http.Request) {
+	var attrs vaultMemberAttributes
+	if err := decodeAttributes(r, &attrs); err != nil || attrs.UserId == "" || attrs.Role == "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_member", "user_id and role are required")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	vaultId := r.PathValue("vault")
+	v, ok := s.findVault(vaultId)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "vault not found")
+		return
+	}
+
+	if !s.isMember(v.OrganizationId, attrs.UserId) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_member", fmt.Sprintf("user %s is not a member of the organization", attrs.UserId))
+		return
+	}
+
+	if s.vaultMemberIndex(vaultId, attrs.UserId) >= 0 {
+		writeError(w, http.StatusConflict, "member_exists", "user is already a member of the vault")
+		return
+	}
+
+	m := VaultMember{VaultId: vaultId, UserId: attrs.UserId, Role: attrs.Role}
+	s.state.VaultMembers = append(s.state.VaultMembers, m)
+	writeDocument(w, http.StatusCreated, s.vaultMemberObject(m))
+}
+
+func (s *Server) updateVaultMember(w http.ResponseWriter, r *http.Request) {
+	var attrs vaultMemberAttributes
+	if err := decodeAttributes(r, &attrs); err != nil || attrs.Role == "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_member", "role is required")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.vaultMemberIndex(r.PathValue("vault"), r.PathValue("user"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "vault member not found")
+		return
+	}
+
+	s.state.VaultMembers[i].Role = attrs.Role
+	writeDocument(w, http.StatusOK, s.vaultMemberObject(s.state.VaultMembers[i]))
+}
+
+func (s *Server) deleteVaultMember(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.vaultMemberIndex(r.PathValue("vault"), r.PathValue("user"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "vault member not found")
+		return
+	}
+
+	s.state.VaultMembers = append(s.state.VaultMembers[:i], s.state.VaultMembers[i+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
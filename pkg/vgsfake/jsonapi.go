@@ -0,0 +1,136 @@
+package vgsfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	contentTypeJSONAPI = "application/vnd.api+json"
+	defaultPageSize    = 20
+)
+
+type resourceObject struct {
+	Id            string         `json:"id"`
+	Type          string         `json:"type"`
+	Attributes    any            `json:"attributes"`
+	Relationships map[string]any `json:"relationships,omitempty"`
+	Links         map[string]any `json:"links,omitempty"`
+}
+
+type errorObject struct {
+	Status string `json:"status"`
+	Code   string `json:"code,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, contentType string, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeDocument(w http.ResponseWriter, status int, data any) {
+	writeJSON(w, status, contentTypeJSONAPI, map[string]any{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, code, detail string) {
+	writeJSON(w, status, contentTypeJSONAPI, map[string]any{
+		"errors": []errorObject{{
+			Status: strconv.Itoa(status),
+			Code:   code,
+			Title:  http.StatusText(status),
+			Detail: detail,
+		}},
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, "application/json", map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// writePage writes one page of objects as selected by the page[number] and page[size] query parameters,
+// with `links.next` pointing at the following page when there is one.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, objects []resourceObject) {
+	query := r.URL.Query()
+	number, err := pageParam(query, "page[number]", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_page", err.Error())
+		return
+	}
+
+	size, err := pageParam(query, "page[size]", defaultPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_page", err.Error())
+		return
+	}
+	if s.maxPageSize > 0 && size > s.maxPageSize {
+		size = s.maxPageSize
+	}
+
+	start := (number - 1) * size
+	end := start + size
+	if start > len(objects) {
+		start = len(objects)
+	}
+	if end > len(objects) {
+		end = len(objects)
+	}
+
+	links := map[string]string{"self": s.pageURL(r, number, size)}
+	if end < len(objects) {
+		links["next"] = s.pageURL(r, number+1, size)
+	}
+
+	page := objects[start:end]
+	if page == nil {
+		page = []resourceObject{}
+	}
+	writeJSON(w, http.StatusOK, contentTypeJSONAPI, map[string]any{
+		"data":  page,
+		"links": links,
+		"meta":  map[string]int{"total": len(objects)},
+	})
+}
+
+func (s *Server) pageURL(r *http.Request, number, size int) string {
+	query := r.URL.Query()
+	query.Set("page[number]", strconv.Itoa(number))
+	query.Set("page[size]", strconv.Itoa(size))
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return s.srv.URL + u.String()
+}
+
+func pageParam(query url.Values, name string, fallback int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+
+	return n, nil
+}
+
+// decodeAttributes reads the attributes of a JSON:API request document.
+func decodeAttributes(r *http.Request, attributes any) error {
+	doc := struct {
+		Data struct {
+			Attributes any `json:"attributes"`
+		} `json:"data"`
+	}{}
+	doc.Data.Attributes = attributes
+
+	return json.NewDecoder(r.Body).Decode(&doc)
+}
@@ -0,0 +1,514 @@
+// Package vgsfake implements an in-process stand-in for the VGS Accounts API and its token endpoint,
+// so the client and connector can be tested without VGS credentials.
+package vgsfake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	// RealmPath is where the fake serves the Keycloak realm; AuthRealmURL points here.
+	RealmPath = "/auth/realms/vgs"
+	// TokenPath is the client credentials token endpoint of the realm.
+	TokenPath = RealmPath + "/protocol/openid-connect/token"
+	// VaultManagementPath is where the fake serves the vault management API advertised in vault links.
+	VaultManagementPath = "/vault-management"
+
+	defaultTokenLifetime = 300
+)
+
+// Scopes enforced by the fake, matching the ones granted to VGS service accounts.
+const (
+	ScopeOrganizationUsersRead  = "organization-users:read"
+	ScopeOrganizationUsersWrite = "organization-users:write"
+	ScopeServiceAccountsRead    = "service-accounts:read"
+	ScopeServiceAccountsWrite   = "service-accounts:write"
+	ScopeCredentialsRead        = "credentials:read"
+	ScopeCredentialsWrite       = "credentials:write"
+	ScopeAuditLogsRead          = "audit-logs:read"
+)
+
+// Call is a request received by the fake.
+type Call struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+	Status int
+}
+
+type failure struct {
+	method string
+	path   string
+	status int
+	times  int
+}
+
+// Server is a fake Accounts API backed by an httptest.Server. It is safe for concurrent use.
+type Server struct {
+	srv *httptest.Server
+
+	mtx           sync.Mutex
+	state         Fixture
+	tokens        map[string]Client
+	calls         []Call
+	failures      []*failure
+	maxPageSize   int
+	tokenLifetime int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxPageSize caps the page size the fake honors, so small fixtures still span several pages.
+func WithMaxPageSize(size int) Option {
+	return func(s *Server) {
+		s.maxPageSize = size
+	}
+}
+
+// WithTokenLifetime sets the expires_in, in seconds, of issued tokens.
+func WithTokenLifetime(seconds int) Option {
+	return func(s *Server) {
+		s.tokenLifetime = seconds
+	}
+}
+
+// New starts a fake seeded with fixture. The server is closed when the test finishes.
+func New(t testing.TB, fixture Fixture, opts ...Option) *Server {
+	t.Helper()
+
+	s := &Server{
+		state:         fixture.clone(),
+		tokens:        map[string]Client{},
+		tokenLifetime: defaultTokenLifetime,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.srv = httptest.NewServer(s.routes())
+	t.Cleanup(s.srv.Close)
+
+	return s
+}
+
+// NewFromFile starts a fake seeded from a JSON fixture file.
+func NewFromFile(t testing.TB, path string, opts ...Option) *Server {
+	t.Helper()
+
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("vgsfake: %v", err)
+	}
+
+	return New(t, fixture, opts...)
+}
+
+// URL is the base URL of the fake.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// AuthRealmURL is the realm URL to configure the client with.
+func (s *Server) AuthRealmURL() string {
+	return s.srv.URL + RealmPath
+}
+
+// AccountsAPIURL is the Accounts API URL to configure the client with.
+func (s *Server) AccountsAPIURL() string {
+	return s.srv.URL
+}
+
+// VaultManagementURL is the vault management API URL advertised in the links of every vault.
+func (s *Server) VaultManagementURL() string {
+	return s.srv.URL + VaultManagementPath
+}
+
+// Seed adds the objects in fixture to the current state.
+func (s *Server) Seed(fixture Fixture) {
+	f := fixture.clone()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.state.Clients = append(s.state.Clients, f.Clients...)
+	s.state.Organizations = append(s.state.Organizations, f.Organizations...)
+	s.state.Members = append(s.state.Members, f.Members...)
+	s.state.Invites = append(s.state.Invites, f.Invites...)
+	s.state.Vaults = append(s.state.Vaults, f.Vaults...)
+	s.state.VaultMembers = append(s.state.VaultMembers, f.VaultMembers...)
+	s.state.VaultCredentials = append(s.state.VaultCredentials, f.VaultCredentials...)
+	s.state.AuditLogs = append(s.state.AuditLogs, f.AuditLogs...)
+}
+
+// State returns a copy of the current state, reflecting every change made through the API.
+func (s *Server) State() Fixture {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.state.clone()
+}
+
+// VaultMember returns the membership of userId in vaultId, if any.
+func (s *Server) VaultMember(vaultId, userId string) (VaultMember, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if i := s.vaultMemberIndex(vaultId, userId); i >= 0 {
+		return s.state.VaultMembers[i], true
+	}
+
+	return VaultMember{}, false
+}
+
+// Member returns the membership of userId in orgId, if any.
+func (s *Server) Member(orgId, userId string) (Member, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if i := s.memberIndex(orgId, userId); i >= 0 {
+		return s.state.Members[i], true
+	}
+
+	return Member{}, false
+}
+
+// Invite returns the invite with the given id, if any.
+func (s *Server) Invite(inviteId string) (Invite, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, i := range s.state.Invites {
+		if i.Id == inviteId {
+			return i, true
+		}
+	}
+
+	return Invite{}, false
+}
+
+// Client returns the client with the given id, if any.
+func (s *Server) Client(clientId string) (Client, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, c := range s.state.Clients {
+		if c.Id == clientId {
+			return c, true
+		}
+	}
+
+	return Client{}, false
+}
+
+// VaultCredential returns the credential of vaultId with the given id, if any.
+func (s *Server) VaultCredential(vaultId, credentialId string) (VaultCredential, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, c := range s.state.VaultCredentials {
+		if c.VaultId == vaultId && c.Id == credentialId {
+			return c, true
+		}
+	}
+
+	return VaultCredential{}, false
+}
+
+// ExpireTokens forgets every issued token, so the next API call is rejected with 401.
+func (s *Server) ExpireTokens() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.tokens = map[string]Client{}
+}
+
+// FailNext makes the next times requests matching method and path answer with status.
+func (s *Server) FailNext(method, path string, status, times int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, times: times})
+}
+
+// Calls returns every request received so far, in order.
+func (s *Server) Calls() []Call {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the requests received for method and path.
+func (s *Server) CallsTo(method, path string) []Call {
+	var calls []Call
+	for _, c := range s.Calls() {
+		if c.Method == method && c.Path == path {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// ResetCalls forgets the requests received so far.
+func (s *Server) ResetCalls() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.calls = nil
+}
+
+// AssertCalled fails the test unless method and path were requested at least once.
+func (s *Server) AssertCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+
+	if len(s.CallsTo(method, path)) == 0 {
+		t.Errorf("vgsfake: expected %s %s to be called, got:\n%s", method, path, s.describeCalls())
+		return false
+	}
+
+	return true
+}
+
+// AssertNotCalled fails the test if method and path were requested.
+func (s *Server) AssertNotCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+
+	if n := len(s.CallsTo(method, path)); n > 0 {
+		t.Errorf("vgsfake: expected %s %s not to be called, got %d calls", method, path, n)
+		return false
+	}
+
+	return true
+}
+
+// AssertCallCount fails the test unless method and path were requested exactly n times.
+func (s *Server) AssertCallCount(t testing.TB, method, path string, n int) bool {
+	t.Helper()
+
+	if got := len(s.CallsTo(method, path)); got != n {
+		t.Errorf("vgsfake: expected %d calls to %s %s, got %d", n, method, path, got)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) describeCalls() string {
+	var sb strings.Builder
+	for _, c := range s.Calls() {
+		fmt.Fprintf(&sb, "  %s %s -> %d\n", c.Method, c.Path, c.Status)
+	}
+
+	return sb.String()
+}
+
+// routes wires the handlers. Every request is recorded and checked against injected failures first.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+TokenPath, s.handleToken)
+
+	mux.Handle("GET /organizations", s.authorized(s.listOrganizations))
+	mux.Handle("GET /organizations/{org}", s.authorized(s.getOrganization))
+	mux.Handle("GET /organizations/{org}/members", s.authorized(s.listMembers, ScopeOrganizationUsersRead))
+	mux.Handle("GET /organizations/{org}/members/{user}", s.authorized(s.getMember, ScopeOrganizationUsersRead))
+	mux.Handle("PUT /organizations/{org}/members/{user}", s.authorized(s.updateMember, ScopeOrganizationUsersWrite))
+	mux.Handle("DELETE /organizations/{org}/members/{user}", s.authorized(s.deleteMember, ScopeOrganizationUsersWrite))
+	mux.Handle("GET /organizations/{org}/invites", s.authorized(s.listInvites, ScopeOrganizationUsersRead))
+	mux.Handle("POST /organizations/{org}/invites", s.authorized(s.createInvite, ScopeOrganizationUsersWrite))
+	mux.Handle("DELETE /organizations/{org}/invites/{invite}", s.authorized(s.deleteInvite, ScopeOrganizationUsersWrite))
+
+	mux.Handle("GET /organizations/{org}/audit-logs", s.authorized(s.listAuditLogs, ScopeAuditLogsRead))
+	mux.Handle("GET /organizations/{org}/service-accounts", s.authorized(s.listServiceAccounts, ScopeServiceAccountsRead))
+	mux.Handle("POST /organizations/{org}/service-accounts/{client}/secrets", s.authorized(s.rotateServiceAccountSecret, ScopeServiceAccountsWrite))
+
+	mux.Handle("GET /vaults", s.authorized(s.listVaults))
+	mux.Handle("GET /vaults/{vault}", s.authorized(s.getVault))
+	mux.Handle("GET /vaults/{vault}/members", s.authorized(s.listVaultMembers, ScopeOrganizationUsersRead))
+	mux.Handle("POST /vaults/{vault}/members", s.authorized(s.createVaultMember, ScopeOrganizationUsersWrite))
+	mux.Handle("PUT /vaults/{vault}/members/{user}", s.authorized(s.updateVaultMember, ScopeOrganizationUsersWrite))
+	mux.Handle("DELETE /vaults/{vault}/members/{user}", s.authorized(s.deleteVaultMember, ScopeOrganizationUsersWrite))
+
+	mux.Handle("GET "+VaultManagementPath+"/vaults/{vault}/credentials", s.authorized(s.listVaultCredentials, ScopeCredentialsRead))
+	mux.Handle("POST "+VaultManagementPath+"/vaults/{vault}/credentials", s.authorized(s.createVaultCredential, ScopeCredentialsWrite))
+	mux.Handle("DELETE "+VaultManagementPath+"/vaults/{vault}/credentials/{credential}", s.authorized(s.deleteVaultCredential, ScopeCredentialsWrite))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if status, ok := s.injectedFailure(r); ok {
+			writeError(rec, status, "injected", "injected failure")
+		} else {
+			mux.ServeHTTP(rec, r)
+		}
+
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		s.calls = append(s.calls, Call{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Body:   body,
+			Status: rec.status,
+		})
+	})
+}
+
+func (s *Server) injectedFailure(r *http.Request) (int, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, f := range s.failures {
+		if f.method != r.Method || f.path != r.URL.Path {
+			continue
+		}
+
+		f.times--
+		if f.times <= 0 {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+		}
+		return f.status, true
+	}
+
+	return 0, false
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || r.FormValue("grant_type") != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client credentials are required")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, c := range s.state.Clients {
+		if c.Id != id || !c.acceptsSecret(secret) {
+			continue
+		}
+
+		token := newToken()
+		s.tokens[token] = c
+		writeJSON(w, http.StatusOK, "application/json", map[string]any{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   s.tokenLifetime,
+			"scope":        strings.Join(c.Scopes, " "),
+		})
+		return
+	}
+
+	writeOAuthError(w, http.StatusUnauthorized, "unauthorized_client", "Invalid client or Invalid client credentials")
+}
+
+// authorized rejects requests without a valid bearer token or missing one of the given scopes.
+func (s *Server) authorized(next http.HandlerFunc, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mtx.Lock()
+		c, known := s.tokens[token]
+		s.mtx.Unlock()
+		if !ok || !known {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid access token")
+			return
+		}
+
+		for _, scope := range scopes {
+			if !hasScope(c, scope) {
+				writeError(w, http.StatusForbidden, "insufficient_scope", fmt.Sprintf("scope %s is required", scope))
+				return
+			}
+		}
+
+		orgId := r.PathValue("org")
+		if vaultId := r.PathValue("vault"); vaultId != "" {
+			s.mtx.Lock()
+			v, ok := s.findVault(vaultId)
+			s.mtx.Unlock()
+			if ok {
+				orgId = v.OrganizationId
+			}
+		}
+		if orgId != "" && !c.canAccess(orgId) {
+			writeError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("organization %s is not accessible", orgId))
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// requestClient returns the client the request's bearer token was issued to. Only call it behind authorized.
+func (s *Server) requestClient(r *http.Request) Client {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.tokens[token]
+}
+
+// canAccess reports whether the client can reach the organization. Clients without an organization reach all of
+// them.
+func (c Client) canAccess(orgId string) bool {
+	return c.OrganizationId == "" || c.OrganizationId == orgId || slices.Contains(c.Organizations, orgId)
+}
+
+func (c Client) acceptsSecret(secret string) bool {
+	if c.Secret == secret {
+		return true
+	}
+
+	for _, previous := range c.PreviousSecrets {
+		if previous == secret {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasScope(c Client, scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newToken() string {
+	return "fake-" + randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,174 @@
+package vgsfake
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tokenForTesting(t *testing.T, s *Server, id, secret string) string {
+	req, err := http.NewRequest(http.MethodPost, s.URL()+TokenPath, strings.NewReader("grant_type=client_credentials"))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(id, secret)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+	}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return body.AccessToken
+}
+
+func doForTesting(t *testing.T, s *Server, method, path, token, body string) (*http.Response, map[string]any) {
+	req, err := http.NewRequest(method, s.URL()+path, strings.NewReader(body))
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentTypeJSONAPI)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+
+	doc := map[string]any{}
+	if len(raw) > 0 {
+		assert.Nil(t, json.Unmarshal(raw, &doc))
+	}
+
+	return resp, doc
+}
+
+func TestLoadFixture(t *testing.T) {
+	f, err := LoadFixture("testdata/basic.json")
+	assert.Nil(t, err)
+	assert.Len(t, f.Clients, 2)
+	assert.Len(t, f.Members, 3)
+	assert.Equal(t, []InviteVault{{Id: "tntsandbox", Role: "write"}}, f.Invites[0].Vaults)
+
+	_, err = LoadFixture("testdata/missing.json")
+	assert.NotNil(t, err)
+}
+
+func TestTokenEndpoint(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json")
+	assert.NotEmpty(t, tokenForTesting(t, s, "ACaaa-reader", "reader-secret"))
+
+	resp, err := http.PostForm(s.URL()+TokenPath, url.Values{"grant_type": {"client_credentials"}})
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = doForTesting(t, s, http.MethodGet, "/organizations", "not-a-token", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestPagination(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json", WithMaxPageSize(2))
+	token := tokenForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	_, doc := doForTesting(t, s, http.MethodGet, "/organizations/ACorg1/members?page[size]=100", token, "")
+	assert.Len(t, doc["data"], 2)
+	next := doc["links"].(map[string]any)["next"].(string)
+	assert.Contains(t, next, "page%5Bnumber%5D=2")
+
+	_, doc = doForTesting(t, s, http.MethodGet, strings.TrimPrefix(next, s.URL()), token, "")
+	assert.Len(t, doc["data"], 1)
+	assert.NotContains(t, doc["links"], "next")
+}
+
+func TestScopeEnforcement(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json")
+	reader := tokenForTesting(t, s, "ACaaa-reader", "reader-secret")
+
+	resp, _ := doForTesting(t, s, http.MethodDelete, "/vaults/tntsandbox/members/IDbob", reader, "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, ok := s.VaultMember("tntsandbox", "IDbob")
+	assert.True(t, ok)
+}
+
+func TestVaultMembership(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json")
+	admin := tokenForTesting(t, s, "ACbbb-admin", "admin-secret")
+
+	resp, _ := doForTesting(t, s, http.MethodPost, "/vaults/tntlive/members", admin, `{"data":{"attributes":{"user_id":"IDcarol","role":"write"}}}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, _ = doForTesting(t, s, http.MethodPost, "/vaults/tntlive/members", admin, `{"data":{"attributes":{"user_id":"IDcarol","role":"write"}}}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, _ = doForTesting(t, s, http.MethodPut, "/vaults/tntlive/members/IDcarol", admin, `{"data":{"attributes":{"role":"admin"}}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	m, ok := s.VaultMember("tntlive", "IDcarol")
+	assert.True(t, ok)
+	assert.Equal(t, "admin", m.Role)
+
+	resp, _ = doForTesting(t, s, http.MethodDelete, "/vaults/tntlive/members/IDcarol", admin, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, ok = s.VaultMember("tntlive", "IDcarol")
+	assert.False(t, ok)
+
+	resp, _ = doForTesting(t, s, http.MethodPut, "/vaults/tntlive/members/IDcarol", admin, `{"data":{"attributes":{"role":"admin"}}}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.AssertCallCount(t, http.MethodPost, "/vaults/tntlive/members", 2)
+	s.AssertCalled(t, http.MethodDelete, "/vaults/tntlive/members/IDcarol")
+	s.AssertNotCalled(t, http.MethodGet, "/vaults")
+}
+
+func TestFailNext(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json")
+	token := tokenForTesting(t, s, "ACaaa-reader", "reader-secret")
+	s.FailNext(http.MethodGet, "/vaults", http.StatusServiceUnavailable, 1)
+
+	resp, _ := doForTesting(t, s, http.MethodGet, "/vaults", token, "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, _ = doForTesting(t, s, http.MethodGet, "/vaults", token, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	calls := s.CallsTo(http.MethodGet, "/vaults")
+	assert.Len(t, calls, 2)
+	assert.Equal(t, http.StatusServiceUnavailable, calls[0].Status)
+}
+
+func TestAuditLogOrder(t *testing.T) {
+	s := NewFromFile(t, "testdata/basic.json")
+	s.Seed(Fixture{Clients: []Client{{Id: "ACccc-audit", Secret: "audit-secret", OrganizationId: "ACorg1", Scopes: []string{ScopeAuditLogsRead}}}})
+	token := tokenForTesting(t, s, "ACccc-audit", "audit-secret")
+
+	occurredAt := func(doc map[string]any) []string {
+		var times []string
+		for _, d := range doc["data"].([]any) {
+			times = append(times, d.(map[string]any)["attributes"].(map[string]any)["occurred_at"].(string))
+		}
+		return times
+	}
+
+	_, doc := doForTesting(t, s, http.MethodGet, "/organizations/ACorg1/audit-logs", token, "")
+	newest := occurredAt(doc)
+	if assert.Greater(t, len(newest), 1) {
+		assert.Greater(t, newest[0], newest[len(newest)-1])
+	}
+
+	_, doc = doForTesting(t, s, http.MethodGet, "/organizations/ACorg1/audit-logs?sort=occurred_at", token, "")
+	oldest := occurredAt(doc)
+	if assert.Greater(t, len(oldest), 1) {
+		assert.Less(t, oldest[0], oldest[len(oldest)-1])
+	}
+
+	resp, _ := doForTesting(t, s, http.MethodGet, "/organizations/ACorg1/audit-logs?sort=action", token, "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}